@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Download archive modes
+const (
+	ArchiveOff    = ""
+	ArchiveFolder = "folder"
+	ArchiveGlobal = "global"
+)
+
+// Archive file names, in the output folder or the data directory
+const (
+	folderArchiveFile = ".hlsdownloader-archive.txt"
+	globalArchiveFile = "archive.txt"
+)
+
+// archiveProbeTimeout bounds how long resolving the video IDs of a URL may take
+const archiveProbeTimeout = 2 * time.Minute
+
+// probeFlags are the extra flags a probe needs to see the same videos as
+// the download: cookies, logins, headers and network routing. The value
+// says whether the flag takes an argument
+var probeFlags = map[string]bool{
+	"--cookies":                     true,
+	"--cookies-from-browser":        true,
+	"-u":                            true,
+	"--username":                    true,
+	"-p":                            true,
+	"--password":                    true,
+	"-2":                            true,
+	"--twofactor":                   true,
+	"-n":                            false,
+	"--netrc":                       false,
+	"--netrc-location":              true,
+	"--netrc-cmd":                   true,
+	"--video-password":              true,
+	"--ap-mso":                      true,
+	"--ap-username":                 true,
+	"--ap-password":                 true,
+	"--client-certificate":          true,
+	"--client-certificate-key":      true,
+	"--client-certificate-password": true,
+	"--add-headers":                 true,
+	"--add-header":                  true,
+	"--user-agent":                  true,
+	"--referer":                     true,
+	"--proxy":                       true,
+	"--impersonate":                 true,
+	"--no-check-certificates":       false,
+}
+
+// ArchiveProbeMsg reports how many videos of a queue item are already archived
+type ArchiveProbeMsg struct {
+	ItemID   int
+	Total    int
+	Archived int
+	Err      error
+}
+
+// archivePath returns the download archive used by o, or "" when off
+func archivePath(o DownloadOptions) string {
+	switch o.Archive {
+	case ArchiveFolder:
+		folder := strings.TrimSpace(o.OutputFolder)
+		if folder == "" {
+			folder = "."
+		}
+		return filepath.Join(folder, folderArchiveFile)
+	case ArchiveGlobal:
+		return filepath.Join(dataDir(), globalArchiveFile)
+	default:
+		return ""
+	}
+}
+
+// nextArchiveMode cycles off → per folder → global
+func nextArchiveMode(mode string) string {
+	switch mode {
+	case ArchiveOff:
+		return ArchiveFolder
+	case ArchiveFolder:
+		return ArchiveGlobal
+	default:
+		return ArchiveOff
+	}
+}
+
+// archiveModeLabel returns the display name of an archive mode
+func archiveModeLabel(mode string) string {
+	switch mode {
+	case ArchiveFolder:
+		return "Per output folder"
+	case ArchiveGlobal:
+		return "Global"
+	default:
+		return "Off"
+	}
+}
+
+// forcedArchivePath returns the archive a forced item records into, so
+// yt-dlp doesn't skip its videos; finishing merges it into the real one
+func forcedArchivePath(itemID int) string {
+	return filepath.Join(dataDir(), fmt.Sprintf("forced-archive-%d.txt", itemID))
+}
+
+// withArchive inserts --download-archive before the URL, which is always
+// the last argument
+func withArchive(cmd Command, path string) Command {
+	n := len(cmd.Args)
+	args := make([]string, 0, n+2)
+	args = append(args, cmd.Args[:n-1]...)
+	args = append(args, "--download-archive", path, cmd.Args[n-1])
+	return Command{Args: args}
+}
+
+// mergeArchive appends the entries of from that path doesn't list yet and
+// removes from; a missing from means nothing was recorded
+func mergeArchive(path, from string) error {
+	recorded, err := readArchive(from)
+	if err != nil {
+		return err
+	}
+	existing, err := readArchive(path)
+	if err != nil {
+		return err
+	}
+
+	var added strings.Builder
+	for _, entry := range slices.Sorted(maps.Keys(recorded)) {
+		if !existing[entry] {
+			added.WriteString(entry + "\n")
+		}
+	}
+	if added.Len() > 0 {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return err
+		}
+		if _, err := f.WriteString(added.String()); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Remove(from); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// readArchive loads the "extractor id" entries of a yt-dlp download archive
+func readArchive(path string) (map[string]bool, error) {
+	entries := make(map[string]bool)
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			entries[line] = true
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// probeArchive resolves the video IDs behind a queue item without
+// downloading and counts how many of them the archive already lists
+func probeArchive(itemID int, o DownloadOptions) tea.Cmd {
+	return func() tea.Msg {
+		msg := ArchiveProbeMsg{ItemID: itemID}
+
+		ctx, cancel := context.WithTimeout(context.Background(), archiveProbeTimeout)
+		defer cancel()
+
+		// Archive entries are "<lowercase extractor key> <id>"
+		args := []string{"--flat-playlist", "--print", "%(ie_key,extractor_key)s %(id)s"}
+		// Only the entries the download would cover; the date and match
+		// filters need metadata a flat listing does not have
+		if !o.Playlist {
+			args = append(args, "--no-playlist")
+		} else {
+			args = append(args, playlistRangeArgs(o)...)
+		}
+		// Validation already rejected unparsable extra flags
+		extra, _ := ParseArgs(o.ExtraFlags)
+		args = append(args, probeArgs(extra)...)
+		args = append(args, strings.TrimSpace(o.URL))
+
+		output, err := exec.CommandContext(ctx, "yt-dlp", args...).Output()
+		if err != nil {
+			msg.Err = err
+			return msg
+		}
+
+		archive, err := readArchive(archivePath(o))
+		if err != nil {
+			msg.Err = err
+			return msg
+		}
+
+		for _, line := range strings.Split(string(output), "\n") {
+			key, id, ok := strings.Cut(strings.TrimSpace(line), " ")
+			if !ok || id == "NA" {
+				continue
+			}
+			msg.Total++
+			if archive[strings.ToLower(key)+" "+id] {
+				msg.Archived++
+			}
+		}
+		return msg
+	}
+}
+
+// probeArgs picks the flags listed in probeFlags, with their values, out
+// of the extra flags of a download
+func probeArgs(extra []string) []string {
+	var args []string
+	for i := 0; i < len(extra); i++ {
+		flag, _, inline := strings.Cut(extra[i], "=")
+		takesValue, ok := probeFlags[flag]
+		if !ok {
+			continue
+		}
+		args = append(args, extra[i])
+		if takesValue && !inline && i+1 < len(extra) {
+			i++
+			args = append(args, extra[i])
+		}
+	}
+	return args
+}
+
+// needsProbe reports whether item must be checked against its archive
+// before it may start
+func (item *QueueItem) needsProbe() bool {
+	return item.Options.Archive != ArchiveOff && !item.Probed && !item.Force
+}
+
+// probeQueue starts archive probes for pending items that have none
+// running, at most jobLimit at a time; each result starts the next one
+func (m *Model) probeQueue() tea.Cmd {
+	var cmds []tea.Cmd
+	for _, item := range m.queue {
+		if len(m.probing) >= m.jobLimit() {
+			break
+		}
+		if item.Status == QueuePending && item.needsProbe() && !m.probing[item.ID] {
+			m.probing[item.ID] = true
+			cmds = append(cmds, probeArchive(item.ID, item.Options))
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+// applyArchiveProbe records a probe result; items whose videos are all
+// archived are held back until downloaded anyway
+func (m *Model) applyArchiveProbe(msg ArchiveProbeMsg) {
+	delete(m.probing, msg.ItemID)
+
+	for _, item := range m.queue {
+		if item.ID != msg.ItemID {
+			continue
+		}
+		// A failed probe doesn't block the download; yt-dlp still
+		// consults the archive itself
+		item.Probed = true
+		item.ArchiveTotal = msg.Total
+		item.ArchiveHits = msg.Archived
+		if msg.Err == nil && msg.Total > 0 && msg.Archived == msg.Total &&
+			item.Status == QueuePending && !item.Force {
+			item.Status = QueueArchived
+		}
+		return
+	}
+}
+
+// downloadAnyway queues archived items again, ignoring the archive
+func (m *Model) downloadAnyway(items []*QueueItem) tea.Cmd {
+	for _, item := range items {
+		if item.Status == QueueArchived {
+			item.Force = true
+			item.Status = QueuePending
+		}
+	}
+	clear(m.marked)
+	return m.scheduleQueue()
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// BatchRejection explains why a line of a batch file was not queued
+type BatchRejection struct {
+	Line   int
+	Reason string
+}
+
+// BatchImport is the result of reading a batch file
+type BatchImport struct {
+	Path     string
+	Items    []DownloadOptions
+	Rejected []BatchRejection
+}
+
+// ParseBatchFile reads URLs in yt-dlp batch-file syntax, one per line,
+// skipping blank lines and lines starting with '#', ';' or ']'. A URL may
+// be followed by key=value overrides, quoted like shell words, e.g.
+//
+//	https://example.com/v/1 subs=1 playlist=0 folder='/media/My Videos'
+//
+// Overrides are applied to a copy of base and every line is validated
+// like the form would be; invalid lines are reported, not queued
+func ParseBatchFile(r io.Reader, base DownloadOptions, features YtDlpFeatures) (BatchImport, error) {
+	var result BatchImport
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		// Same comment markers as yt-dlp's --batch-file
+		if line == "" || strings.ContainsRune("#;]", rune(line[0])) {
+			continue
+		}
+
+		opts, err := parseBatchLine(line, base)
+		if err == nil {
+			err = ValidateInputs(opts)
+		}
+		if err == nil {
+			_, err = BuildCommand(opts, features)
+		}
+		if err != nil {
+			result.Rejected = append(result.Rejected, BatchRejection{Line: lineNo, Reason: err.Error()})
+			continue
+		}
+		result.Items = append(result.Items, opts)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// ReadBatchFile opens path and parses it with ParseBatchFile
+func ReadBatchFile(path string, base DownloadOptions, features YtDlpFeatures) (BatchImport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return BatchImport{Path: path}, err
+	}
+	defer f.Close()
+
+	result, err := ParseBatchFile(f, base, features)
+	result.Path = path
+	return result, err
+}
+
+// parseBatchLine splits a batch line into its URL and overrides
+func parseBatchLine(line string, base DownloadOptions) (DownloadOptions, error) {
+	opts := base
+
+	words, err := ParseArgs(line)
+	if err != nil {
+		return opts, err
+	}
+	if len(words) == 0 {
+		return opts, fmt.Errorf("missing URL")
+	}
+	opts.URL = words[0]
+
+	for _, word := range words[1:] {
+		key, value, ok := strings.Cut(word, "=")
+		if !ok {
+			return opts, fmt.Errorf("expected key=value override, got %q", word)
+		}
+		if err := applyBatchOverride(&opts, strings.ToLower(key), value); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// batchOverride is a key=value override of a batch line; keys lists its
+// name and then its aliases
+type batchOverride struct {
+	keys  []string
+	apply func(opts *DownloadOptions, key, value string) error
+}
+
+// batchOverrides is every override a batch line accepts, in the order
+// the import dialog lists them
+var batchOverrides = []batchOverride{
+	{[]string{"subs", "subtitles"}, batchBool(func(o *DownloadOptions) *bool { return &o.Subtitles })},
+	{[]string{"playlist"}, batchBool(func(o *DownloadOptions) *bool { return &o.Playlist })},
+	{[]string{"detach", "background"}, batchBool(func(o *DownloadOptions) *bool { return &o.Detach })},
+	{[]string{"nice", "low-priority"}, batchBool(func(o *DownloadOptions) *bool { return &o.LowPriority })},
+	{[]string{"folder", "output"}, batchString(func(o *DownloadOptions) *string { return &o.OutputFolder })},
+	{[]string{"concurrent", "n"}, func(opts *DownloadOptions, key, value string) error {
+		if n, err := strconv.Atoi(value); err != nil || n < 1 {
+			return fmt.Errorf("%s must be a positive whole number", key)
+		}
+		opts.Concurrent = value
+		return nil
+	}},
+	{[]string{"stall", "stall-timeout"}, batchString(func(o *DownloadOptions) *string { return &o.StallTimeout })},
+	{[]string{"rate", "rate-limit"}, batchString(func(o *DownloadOptions) *string { return &o.RateLimit })},
+	{[]string{"retries"}, batchString(func(o *DownloadOptions) *string { return &o.Retries })},
+	{[]string{"fragment-retries"}, batchString(func(o *DownloadOptions) *string { return &o.FragmentRetries })},
+	{[]string{"socket-timeout"}, batchString(func(o *DownloadOptions) *string { return &o.SocketTimeout })},
+	{[]string{"flags"}, batchString(func(o *DownloadOptions) *string { return &o.ExtraFlags })},
+	{[]string{"engine"}, func(opts *DownloadOptions, key, value string) error {
+		switch strings.ToLower(value) {
+		case "yt-dlp", "ytdlp":
+			opts.Engine = EngineYtDlp
+		case EngineNative:
+			opts.Engine = EngineNative
+		default:
+			return fmt.Errorf("engine must be yt-dlp or native, got %q", value)
+		}
+		return nil
+	}},
+	{[]string{"live"}, func(opts *DownloadOptions, key, value string) error {
+		switch strings.ToLower(value) {
+		case "off", "0", "no":
+			opts.Live = LiveOff
+		case LiveEdge, LiveStart:
+			opts.Live = strings.ToLower(value)
+		default:
+			return fmt.Errorf("live must be off, edge or start, got %q", value)
+		}
+		return nil
+	}},
+	{[]string{"record-for"}, batchString(func(o *DownloadOptions) *string { return &o.RecordFor })},
+	{[]string{"items", "playlist-items"}, batchString(func(o *DownloadOptions) *string { return &o.PlaylistItems })},
+	{[]string{"start", "playlist-start"}, batchString(func(o *DownloadOptions) *string { return &o.PlaylistStart })},
+	{[]string{"end", "playlist-end"}, batchString(func(o *DownloadOptions) *string { return &o.PlaylistEnd })},
+	{[]string{"reverse"}, batchBool(func(o *DownloadOptions) *bool { return &o.PlaylistReverse })},
+	{[]string{"max", "max-downloads"}, batchString(func(o *DownloadOptions) *string { return &o.MaxDownloads })},
+	{[]string{"after", "date-after"}, batchString(func(o *DownloadOptions) *string { return &o.DateAfter })},
+	{[]string{"before", "date-before"}, batchString(func(o *DownloadOptions) *string { return &o.DateBefore })},
+	{[]string{"match-title"}, batchString(func(o *DownloadOptions) *string { return &o.TitleMatch })},
+	{[]string{"reject-title"}, batchString(func(o *DownloadOptions) *string { return &o.TitleReject })},
+	{[]string{"min-duration"}, batchString(func(o *DownloadOptions) *string { return &o.MinDuration })},
+	{[]string{"max-duration"}, batchString(func(o *DownloadOptions) *string { return &o.MaxDuration })},
+	{[]string{"archive"}, func(opts *DownloadOptions, key, value string) error {
+		switch strings.ToLower(value) {
+		case "off", "0", "no":
+			opts.Archive = ArchiveOff
+		case ArchiveFolder, ArchiveGlobal:
+			opts.Archive = strings.ToLower(value)
+		default:
+			return fmt.Errorf("archive must be off, folder or global, got %q", value)
+		}
+		return nil
+	}},
+}
+
+// batchString returns an override that stores its value as is; the line
+// is validated like the form afterwards
+func batchString(field func(*DownloadOptions) *string) func(*DownloadOptions, string, string) error {
+	return func(opts *DownloadOptions, key, value string) error {
+		*field(opts) = value
+		return nil
+	}
+}
+
+// batchBool returns an override that parses its value with parseBatchBool
+func batchBool(field func(*DownloadOptions) *bool) func(*DownloadOptions, string, string) error {
+	return func(opts *DownloadOptions, key, value string) error {
+		return parseBatchBool(key, value, field(opts))
+	}
+}
+
+// applyBatchOverride sets the option named key on opts
+func applyBatchOverride(opts *DownloadOptions, key, value string) error {
+	for _, override := range batchOverrides {
+		if slices.Contains(override.keys, key) {
+			return override.apply(opts, key, value)
+		}
+	}
+	return fmt.Errorf("unknown override %q", key)
+}
+
+// batchKeyLines lists the name of every override, wrapped to width
+// columns after the "Keys: " label
+func batchKeyLines(width int) []string {
+	const label = "Keys: "
+	var lines []string
+	line := label
+	for _, override := range batchOverrides {
+		key := override.keys[0]
+		if len(line)+len(key) > width && line != label {
+			lines = append(lines, strings.TrimRight(line, " "))
+			line = strings.Repeat(" ", len(label))
+		}
+		line += key + " "
+	}
+	return append(lines, strings.TrimRight(line, " "))
+}
+
+// parseBatchBool parses 1/0, true/false, yes/no and on/off
+func parseBatchBool(key, value string, dst *bool) error {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "on":
+		*dst = true
+	case "0", "false", "no", "off":
+		*dst = false
+	default:
+		return fmt.Errorf("%s must be 1 or 0, got %q", key, value)
+	}
+	return nil
+}
+
+// importBatch queues every accepted item of a batch import and remembers
+// the report for the UI
+func (m *Model) importBatch(result BatchImport) {
+	for _, opts := range result.Items {
+		m.queue = append(m.queue, &QueueItem{ID: m.nextQueueID, Options: opts, Status: QueuePending})
+		m.nextQueueID++
+	}
+	m.importReport = result.Report()
+}
+
+// Report summarises the import for display, one line per rejected line
+func (r BatchImport) Report() []string {
+	lines := []string{fmt.Sprintf("Imported %d item(s) from %s", len(r.Items), r.Path)}
+	if len(r.Rejected) > 0 {
+		lines[0] += fmt.Sprintf("; %d line(s) rejected:", len(r.Rejected))
+	}
+	for _, rej := range r.Rejected {
+		lines = append(lines, fmt.Sprintf("line %d: %s", rej.Line, rej.Reason))
+	}
+	return lines
+}
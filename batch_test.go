@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBatchFile(t *testing.T) {
+	base := DownloadOptions{Concurrent: "4", StallTimeout: "120", OutputFolder: "."}
+	features := YtDlpFeatures{ProgressTemplate: true, PrintWhen: true, NoQuiet: true, MatchFilters: true}
+
+	// with returns base with URL set and edit applied
+	with := func(url string, edit func(*DownloadOptions)) DownloadOptions {
+		opts := base
+		opts.URL = url
+		if edit != nil {
+			edit(&opts)
+		}
+		return opts
+	}
+
+	input := "\ufeffhttps://example.com/a\n" +
+		"\n" +
+		"# a comment\n" +
+		"; another\n" +
+		"] and another\n" +
+		"   \t\n" +
+		"https://example.com/b subs=1 playlist=yes n=8 folder='.' stall=0\n" +
+		"https://example.com/c items=1-3,7 reverse=on max=5 after=2024-01-31 match-title='Part [0-9]+'\n" +
+		"https://example.com/d engine=native live=edge record-for=1:30:00 retries=infinite\n" +
+		"https://example.com/e colour=red\n" +
+		"https://example.com/f subs=maybe\n" +
+		"https://example.com/g n=0\n" +
+		"https://example.com/h stall=soon\n" +
+		"https://example.com/i no-equals-sign\n" +
+		"https://example.com/j title='unterminated\n" +
+		"https://example.com/k engine=native subs=1\n" +
+		"https://example.com/l archive=sometimes\n"
+
+	result, err := ParseBatchFile(strings.NewReader(input), base, features)
+	if err != nil {
+		t.Fatalf("ParseBatchFile: %v", err)
+	}
+
+	wantItems := []DownloadOptions{
+		with("https://example.com/a", nil),
+		with("https://example.com/b", func(o *DownloadOptions) {
+			o.Subtitles, o.Playlist, o.Concurrent, o.StallTimeout = true, true, "8", "0"
+		}),
+		with("https://example.com/c", func(o *DownloadOptions) {
+			o.PlaylistItems, o.PlaylistReverse, o.MaxDownloads = "1-3,7", true, "5"
+			o.DateAfter, o.TitleMatch = "2024-01-31", "Part [0-9]+"
+		}),
+		with("https://example.com/d", func(o *DownloadOptions) {
+			o.Engine, o.Live, o.RecordFor, o.Retries = EngineNative, LiveEdge, "1:30:00", "infinite"
+		}),
+	}
+	if !reflect.DeepEqual(result.Items, wantItems) {
+		t.Errorf("items\n got %+v\nwant %+v", result.Items, wantItems)
+	}
+
+	wantRejected := []struct {
+		line   int
+		reason string
+	}{
+		{10, `unknown override "colour"`},
+		{11, "subs must be 1 or 0"},
+		{12, "n must be a positive whole number"},
+		{13, "Stall timeout"},
+		{14, "expected key=value override"},
+		{15, "quote"},
+		{16, "Subtitles needs the yt-dlp engine"},
+		{17, "archive must be off, folder or global"},
+	}
+	if len(result.Rejected) != len(wantRejected) {
+		t.Fatalf("rejected = %+v, want %d lines", result.Rejected, len(wantRejected))
+	}
+	for i, want := range wantRejected {
+		got := result.Rejected[i]
+		if got.Line != want.line || !strings.Contains(got.Reason, want.reason) {
+			t.Errorf("rejection %d = line %d %q, want line %d containing %q", i, got.Line, got.Reason, want.line, want.reason)
+		}
+	}
+}
+
+func TestBatchKeyLines(t *testing.T) {
+	lines := batchKeyLines(74)
+	listed := strings.Fields(strings.TrimPrefix(strings.Join(lines, " "), "Keys:"))
+
+	var want []string
+	for _, override := range batchOverrides {
+		want = append(want, override.keys[0])
+	}
+	if !reflect.DeepEqual(listed, want) {
+		t.Errorf("keys listed %v, want %v", listed, want)
+	}
+	for _, line := range lines {
+		if len(line) > 74 {
+			t.Errorf("line %q is longer than 74 columns", line)
+		}
+	}
+}
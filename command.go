@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Command holds the exact argv passed to exec.Command
+type Command struct {
+	Args []string
+}
+
+// NewCommand creates a command from a program name and its arguments
+func NewCommand(name string, args ...string) Command {
+	return Command{Args: append([]string{name}, args...)}
+}
+
+// Append adds arguments to the end of the command
+func (c *Command) Append(args ...string) {
+	c.Args = append(c.Args, args...)
+}
+
+// IsEmpty reports whether the command has no program to run
+func (c Command) IsEmpty() bool {
+	return len(c.Args) == 0
+}
+
+// HasFlag reports whether flag is one of the command's arguments
+func (c Command) HasFlag(flag string) bool {
+	for _, arg := range c.Args {
+		if arg == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// Exec creates an *exec.Cmd for the command
+func (c Command) Exec() *exec.Cmd {
+	return exec.Command(c.Args[0], c.Args[1:]...)
+}
+
+// String renders the command quoted so it can be pasted into a POSIX shell
+func (c Command) String() string {
+	quoted := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes s for a POSIX shell, leaving plain words untouched
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	safe := true
+	for _, ch := range s {
+		if !isShellSafe(ch) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+
+	// Single quotes preserve everything except the single quote itself,
+	// which has to be closed, escaped and reopened
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// isShellSafe reports whether ch never needs quoting in a POSIX shell
+func isShellSafe(ch rune) bool {
+	switch {
+	case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		return true
+	}
+	return strings.ContainsRune("-_./:=,+@%", ch)
+}
+
+// ParseArgs splits s into words following POSIX shell quoting rules:
+// single quotes are literal, double quotes allow \ escapes of $ ` " \ and
+// newline, and an unquoted backslash escapes the next character
+func ParseArgs(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inWord := false
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+
+		case ch == '\\':
+			if i+1 >= len(s) {
+				return nil, fmt.Errorf("trailing backslash")
+			}
+			i++
+			// Backslash-newline is a line continuation
+			if s[i] != '\n' {
+				current.WriteByte(s[i])
+				inWord = true
+			}
+
+		case ch == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote at position %d", i+1)
+			}
+			current.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+
+		case ch == '"':
+			start := i
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] == '"' {
+					closed = true
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				current.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated double quote at position %d", start+1)
+			}
+			inWord = true
+
+		default:
+			current.WriteByte(ch)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
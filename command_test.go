@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", input: "", want: nil},
+		{name: "blanks only", input: " \t\n ", want: nil},
+		{name: "plain words", input: "--cookies  c.txt\t-N 4", want: []string{"--cookies", "c.txt", "-N", "4"}},
+		{name: "single quotes are literal", input: `'a b' 'x\y' '$HOME "q"'`, want: []string{"a b", `x\y`, `$HOME "q"`}},
+		{name: "double quotes", input: `"a b" "it's"`, want: []string{"a b", "it's"}},
+		{name: "escapes inside double quotes", input: `"\$ \` + "`" + ` \" \\ \n"`, want: []string{"$ ` \" \\ \\n"}},
+		{name: "escapes outside quotes", input: `a\ b \'c\' \\ \"`, want: []string{"a b", "'c'", `\`, `"`}},
+		{name: "line continuation", input: "--a \\\nb \"c\\\nd\"", want: []string{"--a", "b", "cd"}},
+		{name: "empty single quotes", input: `'' x`, want: []string{"", "x"}},
+		{name: "empty double quotes", input: `--referer ""`, want: []string{"--referer", ""}},
+		{name: "quotes join a word", input: `--add-header 'Cookie: a=1; b'"=2"x`, want: []string{"--add-header", "Cookie: a=1; b=2x"}},
+		{name: "unterminated single quote", input: `--title 'abc`, wantErr: true},
+		{name: "unterminated double quote", input: `--title "abc\"`, wantErr: true},
+		{name: "trailing backslash", input: `abc\`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseArgs(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseArgs(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseArgs(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"--output=/tmp/a_b-c.mp4", "--output=/tmp/a_b-c.mp4"},
+		{"", "''"},
+		{"a b", "'a b'"},
+		{"it's", `'it'\''s'`},
+		{`$HOME`, `'$HOME'`},
+		{"%(title)s.%(ext)s", "'%(title)s.%(ext)s'"},
+	}
+
+	for _, tt := range tests {
+		if got := shellQuote(tt.input); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestShellQuoteRoundTrip(t *testing.T) {
+	words := []string{
+		"", "plain", "a b", "it's", `"double"`, `back\slash`, "tab\there",
+		"new\nline", "$HOME `cmd`", "'", "''", `\`, "ünïcode ✓", "%(title)s [%(id)s].%(ext)s",
+	}
+
+	cmd := NewCommand(words[0], words[1:]...)
+	got, err := ParseArgs(cmd.String())
+	if err != nil {
+		t.Fatalf("ParseArgs(%s): %v", cmd.String(), err)
+	}
+	if !reflect.DeepEqual(got, words) {
+		t.Errorf("round trip of %s\n got %q\nwant %q", cmd.String(), got, words)
+	}
+}
@@ -1,10 +1,9 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
-	"io"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -12,288 +11,178 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-// BuildCommand constructs the yt-dlp command from model state
-func BuildCommand(m Model) string {
-	parts := []string{"yt-dlp"}
+// BuildCommand constructs the yt-dlp command from a snapshot of form options
+func BuildCommand(o DownloadOptions, features YtDlpFeatures) (Command, error) {
+	cmd := NewCommand("yt-dlp")
 
 	// Base format flags
-	parts = append(parts, "-f", `"bv*+ba/b"`)
-	parts = append(parts, "--merge-output-format", "mp4")
+	cmd.Append("-f", "bv*+ba/b")
+	cmd.Append("--merge-output-format", "mp4")
 
 	// Force newline output for better streaming
-	parts = append(parts, "--newline")
+	cmd.Append("--newline")
+
+	// Structured progress and result records, when yt-dlp supports them
+	if features.ProgressTemplate && features.PrintWhen {
+		cmd.Append(recordTemplateArgs()...)
+		// --print implies --quiet and, for early stages, --simulate
+		cmd.Append("--no-simulate", "--progress")
+		if features.NoQuiet {
+			cmd.Append("--no-quiet")
+		}
+	}
 
 	// Concurrent fragments
-	concurrent := strings.TrimSpace(m.concurrent)
+	concurrent := strings.TrimSpace(o.Concurrent)
 	if concurrent != "" && concurrent != "0" {
 		if n, err := strconv.Atoi(concurrent); err == nil && n > 0 {
-			parts = append(parts, "-N", concurrent)
+			cmd.Append("-N", concurrent)
 		}
 	}
 
 	// Output folder
-	folder := strings.TrimSpace(m.outputFolder)
+	folder := strings.TrimSpace(o.OutputFolder)
 	if folder != "" && folder != "." {
-		outputTemplate := fmt.Sprintf(`"%s/%%(title)s.%%(ext)s"`, folder)
-		parts = append(parts, "-o", outputTemplate)
+		cmd.Append("-o", filepath.Join(folder, "%(title)s.%(ext)s"))
+	}
+
+	// Network controls
+	if rate := strings.TrimSpace(o.RateLimit); rate != "" {
+		cmd.Append("-r", rate)
+	}
+	if retries := strings.TrimSpace(o.Retries); retries != "" {
+		cmd.Append("--retries", retries)
+	}
+	if retries := strings.TrimSpace(o.FragmentRetries); retries != "" {
+		cmd.Append("--fragment-retries", retries)
+	}
+	if timeout := strings.TrimSpace(o.SocketTimeout); timeout != "" {
+		cmd.Append("--socket-timeout", timeout)
+	}
+
+	// Skip videos already recorded in the download archive
+	if path := archivePath(o); path != "" {
+		cmd.Append("--download-archive", path)
 	}
 
 	// Subtitles
-	if m.subtitles {
-		parts = append(parts, "--write-subs", "--write-auto-subs")
+	if o.Subtitles {
+		cmd.Append("--write-subs", "--write-auto-subs")
 	}
 
 	// Playlist mode
-	if !m.playlist {
-		parts = append(parts, "--no-playlist")
+	if !o.Playlist {
+		cmd.Append("--no-playlist")
+	} else {
+		cmd.Append(playlistArgs(o, features)...)
 	}
 
 	// Extra flags
-	extraFlags := strings.TrimSpace(m.extraFlags)
-	if extraFlags != "" {
-		parts = append(parts, extraFlags)
+	extraFlags, err := ParseArgs(o.ExtraFlags)
+	if err != nil {
+		return cmd, fmt.Errorf("Invalid extra flags: %s", err.Error())
 	}
+	cmd.Append(extraFlags...)
 
 	// URL (last)
-	url := strings.TrimSpace(m.url)
-	parts = append(parts, url)
-
-	return strings.Join(parts, " ")
-}
-
-// ExecuteDownload runs the yt-dlp command and streams output
-func ExecuteDownload(cmd string) tea.Cmd {
-	return func() tea.Msg {
-		// Parse command to execute
-		cmdParts := parseCommand(cmd)
-		if len(cmdParts) == 0 {
-			return DownloadCompleteMsg{Success: false, ExitCode: 1}
-		}
-
-		// Create command
-		execCmd := exec.Command(cmdParts[0], cmdParts[1:]...)
-
-		// Get stdout and stderr pipes
-		stdout, err := execCmd.StdoutPipe()
-		if err != nil {
-			return DownloadCompleteMsg{Success: false, ExitCode: 1}
-		}
-
-		stderr, err := execCmd.StderrPipe()
-		if err != nil {
-			return DownloadCompleteMsg{Success: false, ExitCode: 1}
-		}
+	url := strings.TrimSpace(o.URL)
+	cmd.Append(url)
 
-		// Start command
-		if err := execCmd.Start(); err != nil {
-			return DownloadCompleteMsg{Success: false, ExitCode: 1}
-		}
-
-		// Create channels for output streaming
-		outputChan := make(chan string, 100)
-		doneChan := make(chan bool)
-
-		// Stream stdout
-		go streamOutput(stdout, outputChan)
-
-		// Stream stderr
-		go streamOutput(stderr, outputChan)
-
-		// Send output to UI in separate goroutine
-		go func() {
-			for range outputChan {
-				// This would need to be sent via a channel back to the Update function
-				// For now, we'll collect it in the command execution
-			}
-			doneChan <- true
-		}() // Wait for command to complete
-		err = execCmd.Wait()
-		close(outputChan)
-		<-doneChan
-
-		success := err == nil
-		exitCode := 0
-		if err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				exitCode = exitErr.ExitCode()
-			} else {
-				exitCode = 1
-			}
-		}
-
-		return DownloadCompleteMsg{
-			Success:  success,
-			ExitCode: exitCode,
-		}
+	// Reduced CPU/IO priority, inherited by ffmpeg children
+	if o.LowPriority {
+		cmd = withLowPriority(cmd, features)
 	}
-}
-
-// ExecuteDownloadWithStreaming runs yt-dlp and streams output line by line
-func ExecuteDownloadWithStreaming(cmd string) tea.Cmd {
-	return func() tea.Msg {
-		cmdParts := parseCommand(cmd)
-		if len(cmdParts) == 0 {
-			return DownloadCompleteMsg{Success: false, ExitCode: 1}
-		}
-
-		execCmd := exec.Command(cmdParts[0], cmdParts[1:]...)
-
-		stdout, err := execCmd.StdoutPipe()
-		if err != nil {
-			return DownloadCompleteMsg{Success: false, ExitCode: 1}
-		}
-
-		stderr, err := execCmd.StderrPipe()
-		if err != nil {
-			return DownloadCompleteMsg{Success: false, ExitCode: 1}
-		}
-
-		if err := execCmd.Start(); err != nil {
-			return DownloadCompleteMsg{Success: false, ExitCode: 1}
-		}
 
-		// Read output in current goroutine
-		go func() {
-			scanner := bufio.NewScanner(stdout)
-			for scanner.Scan() {
-				// Output will be handled by batch reading
-			}
-		}()
+	return cmd, nil
+}
 
-		go func() {
-			scanner := bufio.NewScanner(stderr)
-			for scanner.Scan() {
-				// Output will be handled by batch reading
-			}
-		}()
+// playlistArgs maps the picked entries and the Playlist Mode range and
+// filter fields to yt-dlp options
+func playlistArgs(o DownloadOptions, features YtDlpFeatures) []string {
+	args := playlistRangeArgs(o)
 
-		err = execCmd.Wait()
-		success := err == nil
-		exitCode := 0
-		if err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				exitCode = exitErr.ExitCode()
-			} else {
-				exitCode = 1
-			}
-		}
+	// Dates were validated, so normalizing cannot fail here
+	if after, _ := normalizeDate(o.DateAfter); after != "" {
+		args = append(args, "--dateafter", after)
+	}
+	if before, _ := normalizeDate(o.DateBefore); before != "" {
+		args = append(args, "--datebefore", before)
+	}
 
-		return DownloadCompleteMsg{
-			Success:  success,
-			ExitCode: exitCode,
+	// Title and duration filters combine into one match filter, since
+	// repeated match filters are alternatives rather than conditions
+	if filter := playlistMatchFilter(o); filter != "" {
+		flag := "--match-filter"
+		if features.MatchFilters {
+			flag = "--match-filters"
 		}
+		args = append(args, flag, filter)
 	}
+	return args
 }
 
-// StartDownload initiates download with streaming output
-func StartDownload(m *Model) tea.Cmd {
-	cmd := BuildCommand(*m)
-	m.downloadCmd = cmd
-	m.downloading = true
-	m.downloadOutput = []string{}
-	m.downloadSuccess = nil
-	m.err = ""
-
-	return tea.Batch(
-		executeDownloadStreaming(cmd),
-		tickSpinner(),
-	)
-}
-
-// executeDownloadStreaming runs yt-dlp and sends output messages
-func executeDownloadStreaming(cmdStr string) tea.Cmd {
-	return func() tea.Msg {
-		cmdParts := parseCommand(cmdStr)
-		if len(cmdParts) == 0 {
-			return DownloadCompleteMsg{Success: false, ExitCode: 1}
-		}
+// playlistRangeArgs maps the picked entries, range, order and download
+// limit, which select entries by position alone
+func playlistRangeArgs(o DownloadOptions) []string {
+	var args []string
 
-		execCmd := exec.Command(cmdParts[0], cmdParts[1:]...)
-
-		// Combine stdout and stderr
-		stdout, err := execCmd.StdoutPipe()
-		if err != nil {
-			return DownloadCompleteMsg{Success: false, ExitCode: 1}
+	// Picked entries replace the start/end range
+	if o.PlaylistItems != "" {
+		args = append(args, "--playlist-items", o.PlaylistItems)
+	} else {
+		if start := strings.TrimSpace(o.PlaylistStart); start != "" {
+			args = append(args, "--playlist-start", start)
 		}
-
-		stderr, err := execCmd.StderrPipe()
-		if err != nil {
-			return DownloadCompleteMsg{Success: false, ExitCode: 1}
-		}
-
-		if err := execCmd.Start(); err != nil {
-			return DownloadCompleteMsg{Success: false, ExitCode: 1}
-		}
-
-		// Read both streams
-		go streamToUI(stdout)
-		go streamToUI(stderr)
-
-		err = execCmd.Wait()
-		success := err == nil
-		exitCode := 0
-		if err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				exitCode = exitErr.ExitCode()
-			} else {
-				exitCode = 1
-			}
-		}
-
-		return DownloadCompleteMsg{
-			Success:  success,
-			ExitCode: exitCode,
+		if end := strings.TrimSpace(o.PlaylistEnd); end != "" {
+			args = append(args, "--playlist-end", end)
 		}
 	}
-}
-
-// streamToUI reads from reader and sends to UI (placeholder for actual implementation)
-func streamToUI(reader io.Reader) {
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		// Lines will be captured by the actual execution
-		_ = scanner.Text()
+	if o.PlaylistReverse {
+		args = append(args, "--playlist-reverse")
 	}
+	if max := strings.TrimSpace(o.MaxDownloads); max != "" {
+		args = append(args, "--max-downloads", max)
+	}
+	return args
 }
 
-// streamOutput reads lines from reader and sends to channel
-func streamOutput(reader io.Reader, outputChan chan<- string) {
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		outputChan <- scanner.Text()
+// playlistMatchFilter builds a yt-dlp match filter from the title and
+// duration fields; entries without a known duration are kept
+func playlistMatchFilter(o DownloadOptions) string {
+	var conditions []string
+	if pattern := strings.TrimSpace(o.TitleMatch); pattern != "" {
+		conditions = append(conditions, "title ~= "+quoteFilterValue(pattern))
+	}
+	if pattern := strings.TrimSpace(o.TitleReject); pattern != "" {
+		conditions = append(conditions, "title !~= "+quoteFilterValue(pattern))
+	}
+	if d := strings.TrimSpace(o.MinDuration); d != "" {
+		conditions = append(conditions, fmt.Sprintf("duration >=? %d", int(parseClock(d).Seconds())))
 	}
+	if d := strings.TrimSpace(o.MaxDuration); d != "" {
+		conditions = append(conditions, fmt.Sprintf("duration <=? %d", int(parseClock(d).Seconds())))
+	}
+	return strings.Join(conditions, " & ")
 }
 
-// parseCommand splits command string into parts, respecting quotes
-func parseCommand(cmd string) []string {
-	var parts []string
-	var current strings.Builder
-	inQuote := false
-
-	for i := 0; i < len(cmd); i++ {
-		ch := cmd[i]
-
-		if ch == '"' {
-			inQuote = !inQuote
-			continue
-		}
-
-		if ch == ' ' && !inQuote {
-			if current.Len() > 0 {
-				parts = append(parts, current.String())
-				current.Reset()
-			}
-			continue
-		}
+// quoteFilterValue quotes a match filter value, escaping the quote and
+// the & that would otherwise split the filter
+func quoteFilterValue(value string) string {
+	value = strings.ReplaceAll(value, "'", `\'`)
+	value = strings.ReplaceAll(value, "&", `\&`)
+	return "'" + value + "'"
+}
 
-		current.WriteByte(ch)
+// withLowPriority prefixes cmd with nice and ionice when they are installed
+func withLowPriority(cmd Command, features YtDlpFeatures) Command {
+	var prefix []string
+	if features.Nice {
+		prefix = append(prefix, "nice", "-n", "19")
 	}
-
-	if current.Len() > 0 {
-		parts = append(parts, current.String())
+	if features.Ionice {
+		prefix = append(prefix, "ionice", "-c", "3")
 	}
-
-	return parts
+	return Command{Args: append(prefix, cmd.Args...)}
 }
 
 // tickSpinner returns a command that sends tick messages for spinner animation
@@ -303,6 +192,41 @@ func tickSpinner() tea.Cmd {
 	})
 }
 
+// YtDlpFeatures lists optional yt-dlp capabilities of the installed
+// version, and the priority tools available to run it with
+type YtDlpFeatures struct {
+	ProgressTemplate bool
+	PrintWhen        bool
+	NoQuiet          bool
+	MatchFilters     bool
+
+	// nice and ionice are looked up once, not on every command build
+	Nice   bool
+	Ionice bool
+}
+
+// DetectYtDlpFeatures inspects yt-dlp --help for optional capabilities
+// and looks up nice and ionice in PATH
+func DetectYtDlpFeatures() YtDlpFeatures {
+	var features YtDlpFeatures
+	_, err := exec.LookPath("nice")
+	features.Nice = err == nil
+	_, err = exec.LookPath("ionice")
+	features.Ionice = err == nil
+
+	output, err := exec.Command("yt-dlp", "--help").Output()
+	if err != nil {
+		return features
+	}
+
+	help := string(output)
+	features.ProgressTemplate = strings.Contains(help, "--progress-template")
+	features.PrintWhen = strings.Contains(help, "[WHEN:]TEMPLATE")
+	features.NoQuiet = strings.Contains(help, "--no-quiet")
+	features.MatchFilters = strings.Contains(help, "--match-filters")
+	return features
+}
+
 // CheckYtDlpAvailable checks if yt-dlp is in PATH
 func CheckYtDlpAvailable() bool {
 	_, err := exec.LookPath("yt-dlp")
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlaylistMatchFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		opts DownloadOptions
+		want string
+	}{
+		{name: "no filters", want: ""},
+		{name: "title match", opts: DownloadOptions{TitleMatch: " Part \\d+ "}, want: `title ~= 'Part \d+'`},
+		{name: "title reject", opts: DownloadOptions{TitleReject: "trailer"}, want: "title !~= 'trailer'"},
+		{name: "quote and ampersand escaped", opts: DownloadOptions{TitleMatch: "Tom & Jerry's"}, want: `title ~= 'Tom \& Jerry\'s'`},
+		{name: "min seconds", opts: DownloadOptions{MinDuration: "90"}, want: "duration >=? 90"},
+		{name: "max m:s", opts: DownloadOptions{MaxDuration: "1:30"}, want: "duration <=? 90"},
+		{name: "max h:m:s", opts: DownloadOptions{MaxDuration: "1:02:03"}, want: "duration <=? 3723"},
+		{
+			name: "all conditions joined",
+			opts: DownloadOptions{TitleMatch: "live", TitleReject: "teaser", MinDuration: "60", MaxDuration: "2:00:00"},
+			want: "title ~= 'live' & title !~= 'teaser' & duration >=? 60 & duration <=? 7200",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := playlistMatchFilter(tt.opts); got != tt.want {
+				t.Errorf("playlistMatchFilter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaylistArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     DownloadOptions
+		features YtDlpFeatures
+		want     []string
+	}{
+		{name: "nothing set", want: nil},
+		{
+			name: "range, order and limit",
+			opts: DownloadOptions{PlaylistStart: "3", PlaylistEnd: " 9 ", PlaylistReverse: true, MaxDownloads: "5"},
+			want: []string{"--playlist-start", "3", "--playlist-end", "9", "--playlist-reverse", "--max-downloads", "5"},
+		},
+		{
+			name: "picked items replace the range",
+			opts: DownloadOptions{PlaylistItems: "1-3,7", PlaylistStart: "3", PlaylistEnd: "9"},
+			want: []string{"--playlist-items", "1-3,7"},
+		},
+		{
+			name: "dates normalized",
+			opts: DownloadOptions{DateAfter: "2024-01-31", DateBefore: "Today-2weeks"},
+			want: []string{"--dateafter", "20240131", "--datebefore", "today-2weeks"},
+		},
+		{
+			name: "old match filter flag",
+			opts: DownloadOptions{MinDuration: "60"},
+			want: []string{"--match-filter", "duration >=? 60"},
+		},
+		{
+			name:     "new match filters flag",
+			opts:     DownloadOptions{MinDuration: "60"},
+			features: YtDlpFeatures{MatchFilters: true},
+			want:     []string{"--match-filters", "duration >=? 60"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := playlistArgs(tt.opts, tt.features); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("playlistArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
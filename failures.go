@@ -0,0 +1,180 @@
+package main
+
+import (
+	"strings"
+)
+
+// FailureKind classifies why a download failed
+type FailureKind int
+
+const (
+	FailureUnknown FailureKind = iota
+	FailureHTTP403
+	FailureHTTP404
+	FailureHTTP429
+	FailureGeoRestricted
+	FailureLoginRequired
+	FailureUnavailable
+	FailureUnsupportedURL
+	FailureFFmpegMissing
+	FailureDiskFull
+	FailureStalled
+	FailureDRM
+)
+
+// String returns a short description of the failure kind
+func (k FailureKind) String() string {
+	switch k {
+	case FailureHTTP403:
+		return "HTTP 403 Forbidden"
+	case FailureHTTP404:
+		return "HTTP 404 Not Found"
+	case FailureHTTP429:
+		return "HTTP 429 Too Many Requests"
+	case FailureGeoRestricted:
+		return "Geo-restricted"
+	case FailureLoginRequired:
+		return "Login or age verification required"
+	case FailureUnavailable:
+		return "Video private or removed"
+	case FailureUnsupportedURL:
+		return "Unsupported URL"
+	case FailureFFmpegMissing:
+		return "ffmpeg missing"
+	case FailureDiskFull:
+		return "Disk full"
+	case FailureStalled:
+		return "Download kept stalling"
+	case FailureDRM:
+		return "DRM-protected"
+	default:
+		return "Unknown error"
+	}
+}
+
+// failureKindNames are the names failure kinds are saved under; unlike
+// String they never change
+var failureKindNames = map[FailureKind]string{
+	FailureUnknown:        "unknown",
+	FailureHTTP403:        "http-403",
+	FailureHTTP404:        "http-404",
+	FailureHTTP429:        "http-429",
+	FailureGeoRestricted:  "geo-restricted",
+	FailureLoginRequired:  "login-required",
+	FailureUnavailable:    "unavailable",
+	FailureUnsupportedURL: "unsupported-url",
+	FailureFFmpegMissing:  "ffmpeg-missing",
+	FailureDiskFull:       "disk-full",
+	FailureStalled:        "stalled",
+	FailureDRM:            "drm",
+}
+
+// MarshalText stores the kind by name in the queue and job state files
+func (k FailureKind) MarshalText() ([]byte, error) {
+	name, ok := failureKindNames[k]
+	if !ok {
+		name = failureKindNames[FailureUnknown]
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText parses a kind name written by MarshalText; names it does
+// not know, e.g. from a newer version, read as FailureUnknown
+func (k *FailureKind) UnmarshalText(text []byte) error {
+	*k = FailureUnknown
+	for kind, name := range failureKindNames {
+		if name == string(text) {
+			*k = kind
+			break
+		}
+	}
+	return nil
+}
+
+// Hint returns a suggestion for fixing the failure
+func (k FailureKind) Hint() string {
+	switch k {
+	case FailureHTTP403:
+		return "Update yt-dlp (pip install -U yt-dlp) or pass cookies with --cookies-from-browser firefox"
+	case FailureHTTP404:
+		return "The media no longer exists at this address; check the URL"
+	case FailureHTTP429:
+		return "The site is rate limiting; wait, lower Concurrent Fragments or add --sleep-requests 1"
+	case FailureGeoRestricted:
+		return "Try --geo-bypass or route through a proxy with --proxy"
+	case FailureLoginRequired:
+		return "Try --cookies-from-browser firefox (or chrome) with a signed-in browser"
+	case FailureUnavailable:
+		return "Nothing to download; the uploader made the video private or it was taken down"
+	case FailureUnsupportedURL:
+		return "Check the URL or update yt-dlp to get newer extractors"
+	case FailureFFmpegMissing:
+		return "Install ffmpeg (apt install ffmpeg / brew install ffmpeg) to merge video and audio"
+	case FailureDiskFull:
+		return "Free up space or choose another output folder"
+	case FailureStalled:
+		return "Lower Concurrent Fragments or raise the stall timeout and try again later"
+	case FailureDRM:
+		return "The stream is DRM-protected or uses SAMPLE-AES; neither engine can decrypt it"
+	default:
+		return "Press l to read the raw yt-dlp log"
+	}
+}
+
+// Failure is a classified download failure
+type Failure struct {
+	Kind    FailureKind `json:"kind"`
+	Message string      `json:"message"`
+}
+
+// Hint returns a suggestion for fixing the failure
+func (f Failure) Hint() string {
+	return f.Kind.Hint()
+}
+
+// failurePatterns maps lowercase stderr substrings to failure kinds, most
+// specific first
+var failurePatterns = []struct {
+	kind     FailureKind
+	patterns []string
+}{
+	{FailureDiskFull, []string{"no space left on device", "[errno 28]"}},
+	{FailureDRM, []string{"drm protected", "drm-protected"}},
+	{FailureFFmpegMissing, []string{"ffmpeg is not installed", "ffmpeg not found", "ffprobe and ffmpeg not found", "ffprobe/avprobe and ffmpeg/avconv not found"}},
+	{FailureUnsupportedURL, []string{"unsupported url", "is not a valid url"}},
+	{FailureGeoRestricted, []string{"not available in your country", "geo restrict", "geo-restrict", "not available from your location"}},
+	{FailureLoginRequired, []string{"sign in to confirm your age", "age-restricted", "age restricted", "login required", "requires login", "use --cookies", "sign in to confirm you"}},
+	{FailureUnavailable, []string{"private video", "video is private", "video unavailable", "has been removed", "has been terminated", "no longer available"}},
+	{FailureHTTP429, []string{"http error 429", "too many requests"}},
+	{FailureHTTP403, []string{"http error 403", "403: forbidden"}},
+	{FailureHTTP404, []string{"http error 404", "404: not found"}},
+}
+
+// ClassifyFailure determines the failure kind from yt-dlp's stderr
+func ClassifyFailure(stderr []string) Failure {
+	for _, rule := range failurePatterns {
+		for _, line := range stderr {
+			lower := strings.ToLower(line)
+			for _, pattern := range rule.patterns {
+				if strings.Contains(lower, pattern) {
+					return Failure{Kind: rule.kind, Message: strings.TrimSpace(line)}
+				}
+			}
+		}
+	}
+
+	return Failure{Kind: FailureUnknown, Message: lastErrorLine(stderr)}
+}
+
+// lastErrorLine returns the last "ERROR:" line, or the last line if none
+func lastErrorLine(lines []string) string {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.HasPrefix(lines[i], "ERROR:") {
+			return strings.TrimSpace(lines[i])
+		}
+	}
+	if len(lines) > 0 {
+		return strings.TrimSpace(lines[len(lines)-1])
+	}
+	return ""
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		stderr  []string
+		kind    FailureKind
+		message string
+	}{
+		{
+			name:    "403 on a fragment",
+			stderr:  []string{"WARNING: [youtube] Unable to download format 137", "ERROR: unable to download video data: HTTP Error 403: Forbidden"},
+			kind:    FailureHTTP403,
+			message: "ERROR: unable to download video data: HTTP Error 403: Forbidden",
+		},
+		{
+			name:   "404",
+			stderr: []string{"ERROR: [generic] Unable to download webpage: HTTP Error 404: Not Found (caused by <HTTPError 404: Not Found>)"},
+			kind:   FailureHTTP404,
+		},
+		{
+			name:   "429",
+			stderr: []string{"ERROR: [youtube] abc123: Unable to download API page: HTTP Error 429: Too Many Requests"},
+			kind:   FailureHTTP429,
+		},
+		{
+			name:   "geo-restricted",
+			stderr: []string{"ERROR: [BBCiPlayer] b0abcdef: This video is not available in your country due to geo restriction"},
+			kind:   FailureGeoRestricted,
+		},
+		{
+			name:   "age gate",
+			stderr: []string{"ERROR: [youtube] abc123: Sign in to confirm your age. This video may be inappropriate for some users. Use --cookies-from-browser or --cookies for the authentication."},
+			kind:   FailureLoginRequired,
+		},
+		{
+			name:   "bot check",
+			stderr: []string{"ERROR: [youtube] abc123: Sign in to confirm you’re not a bot. Use --cookies-from-browser or --cookies for the authentication."},
+			kind:   FailureLoginRequired,
+		},
+		{
+			name:   "private video",
+			stderr: []string{"ERROR: [youtube] abc123: Private video. Sign in if you've been granted access to this video"},
+			kind:   FailureUnavailable,
+		},
+		{
+			name:   "removed video",
+			stderr: []string{"ERROR: [youtube] abc123: Video unavailable. This video has been removed by the uploader"},
+			kind:   FailureUnavailable,
+		},
+		{
+			name:   "unsupported URL",
+			stderr: []string{"ERROR: Unsupported URL: https://example.com/page"},
+			kind:   FailureUnsupportedURL,
+		},
+		{
+			name:   "invalid URL",
+			stderr: []string{"ERROR: 'not-a-url' is not a valid URL. Set --default-search \"ytsearch\" (or run  yt-dlp \"ytsearch:not-a-url\" ) to search YouTube"},
+			kind:   FailureUnsupportedURL,
+		},
+		{
+			name:   "ffmpeg missing",
+			stderr: []string{"ERROR: You have requested merging of multiple formats but ffmpeg is not installed. Aborting due to --abort-on-error"},
+			kind:   FailureFFmpegMissing,
+		},
+		{
+			name:   "ffprobe missing",
+			stderr: []string{"ERROR: Postprocessing: ffprobe and ffmpeg not found. Please install or provide the path using --ffmpeg-location"},
+			kind:   FailureFFmpegMissing,
+		},
+		{
+			name:   "disk full",
+			stderr: []string{"ERROR: unable to write data: [Errno 28] No space left on device"},
+			kind:   FailureDiskFull,
+		},
+		{
+			name:   "DRM",
+			stderr: []string{"ERROR: [Netflix] 80012345: This video is DRM protected"},
+			kind:   FailureDRM,
+		},
+		{
+			name:   "more specific kind wins over an earlier line",
+			stderr: []string{"WARNING: HTTP Error 403: Forbidden, retrying", "ERROR: unable to write data: [Errno 28] No space left on device"},
+			kind:   FailureDiskFull,
+		},
+		{
+			name:    "unknown keeps the last ERROR line",
+			stderr:  []string{"ERROR: something odd", "Traceback (most recent call last):", "ERROR: something odder", "  File \"yt_dlp/__main__.py\""},
+			kind:    FailureUnknown,
+			message: "ERROR: something odder",
+		},
+		{
+			name:    "unknown without ERROR lines keeps the last line",
+			stderr:  []string{"WARNING: first", "  last line  "},
+			kind:    FailureUnknown,
+			message: "last line",
+		},
+		{
+			name: "nothing on stderr",
+			kind: FailureUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyFailure(tt.stderr)
+			if got.Kind != tt.kind {
+				t.Errorf("kind = %v, want %v", got.Kind, tt.kind)
+			}
+			if tt.message != "" && got.Message != tt.message {
+				t.Errorf("message = %q, want %q", got.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestFailureJSON(t *testing.T) {
+	data, err := json.Marshal(Failure{Kind: FailureHTTP429, Message: "slow down"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"kind":"http-429","message":"slow down"}`; got != want {
+		t.Errorf("json = %s, want %s", got, want)
+	}
+
+	for kind := FailureUnknown; kind <= FailureDRM; kind++ {
+		data, err := json.Marshal(Failure{Kind: kind})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Failure
+		if err := json.Unmarshal(data, &back); err != nil || back.Kind != kind {
+			t.Errorf("%v round-tripped through %s to %v, %v", kind, data, back.Kind, err)
+		}
+	}
+
+	var future Failure
+	if err := json.Unmarshal([]byte(`{"kind":"quantum","message":"?"}`), &future); err != nil || future.Kind != FailureUnknown {
+		t.Errorf("unknown name read as %v, %v; want FailureUnknown", future.Kind, err)
+	}
+}
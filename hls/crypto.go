@@ -0,0 +1,116 @@
+package hls
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnsupportedEncryption is returned for SAMPLE-AES and DRM-protected
+// playlists, which cannot be decrypted into a playable file
+var ErrUnsupportedEncryption = fmt.Errorf("%w: encryption", ErrUnsupported)
+
+// drmKeyFormats names the KEYFORMAT values of common DRM systems
+var drmKeyFormats = map[string]string{
+	"com.apple.streamingkeydelivery":                "FairPlay",
+	"urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed": "Widevine",
+	"com.microsoft.playready":                       "PlayReady",
+	"urn:uuid:9a04f079-9840-4286-ab92-e65be0885f95": "PlayReady",
+}
+
+// checkKey reports whether segments encrypted with key can be decrypted
+func checkKey(key *Key) error {
+	if key == nil {
+		return nil
+	}
+
+	format := key.KeyFormat
+	if format != "" && format != "identity" {
+		name := drmKeyFormats[format]
+		if name == "" {
+			name = "KEYFORMAT " + format
+		}
+		return fmt.Errorf("%w: %s with %s DRM is not supported", ErrUnsupportedEncryption, key.Method, name)
+	}
+	if key.Method != "AES-128" {
+		return fmt.Errorf("%w: METHOD=%s is not supported", ErrUnsupportedEncryption, key.Method)
+	}
+	return nil
+}
+
+// keyCache fetches each key URI once per download; keys that fail to
+// load are not cached, so a retried segment fetches them again
+type keyCache struct {
+	mu    sync.Mutex
+	keys  map[string][]byte
+	fetch func(ctx context.Context, uri string) ([]byte, error)
+}
+
+// newKeyCache returns an empty cache loading keys with fetch
+func newKeyCache(fetch func(ctx context.Context, uri string) ([]byte, error)) *keyCache {
+	return &keyCache{keys: make(map[string][]byte), fetch: fetch}
+}
+
+// get returns the 16-byte AES key at uri
+func (c *keyCache) get(ctx context.Context, uri string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if key, ok := c.keys[uri]; ok {
+		return key, nil
+	}
+	key, err := c.fetch(ctx, uri)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", uri, err)
+	}
+	if len(key) != aes.BlockSize {
+		return nil, fmt.Errorf("key %s is %d bytes, expected %d", uri, len(key), aes.BlockSize)
+	}
+	c.keys[uri] = key
+	return key, nil
+}
+
+// segmentIV returns the explicit IV of the key, or the media sequence
+// number as a 128-bit big-endian value
+func segmentIV(key *Key, sequence uint64) []byte {
+	if key.IV != nil {
+		return key.IV
+	}
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], sequence)
+	return iv
+}
+
+// decryptSegment decrypts an AES-128 CBC segment and strips its PKCS7
+// padding
+func decryptSegment(data, key, iv []byte) ([]byte, error) {
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted size %d is not a multiple of %d", len(data), aes.BlockSize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	// A wrong key or IV almost always shows up as broken padding
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, errBadPadding
+	}
+	for _, b := range plain[len(plain)-pad:] {
+		if int(b) != pad {
+			return nil, errBadPadding
+		}
+	}
+	return plain[:len(plain)-pad], nil
+}
+
+// errBadPadding means a segment did not decrypt to valid PKCS7 padding
+var errBadPadding = errors.New("invalid PKCS7 padding after decryption (wrong key or IV?)")
@@ -0,0 +1,253 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// encrypt pads plain with PKCS7 and encrypts it with AES-128 CBC
+func encrypt(t *testing.T, plain, key, iv []byte) []byte {
+	t.Helper()
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append(bytes.Clone(plain), bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, padded)
+	return out
+}
+
+func TestSegmentIV(t *testing.T) {
+	explicit := bytes.Repeat([]byte{0xab}, aes.BlockSize)
+	tests := []struct {
+		name     string
+		key      *Key
+		sequence uint64
+		want     []byte
+	}{
+		{
+			name: "sequence zero",
+			key:  &Key{Method: "AES-128"},
+			want: make([]byte, aes.BlockSize),
+		},
+		{
+			name:     "from the media sequence",
+			key:      &Key{Method: "AES-128"},
+			sequence: 0x0102030405,
+			want:     []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0x02, 0x03, 0x04, 0x05},
+		},
+		{
+			name:     "explicit IV wins",
+			key:      &Key{Method: "AES-128", IV: explicit},
+			sequence: 7,
+			want:     explicit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := segmentIV(tt.key, tt.sequence); !bytes.Equal(got, tt.want) {
+				t.Errorf("segmentIV = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKeyIV(t *testing.T) {
+	input := `#EXTM3U
+#EXT-X-TARGETDURATION:2
+#EXT-X-KEY:METHOD=AES-128,URI="k",IV=0x000102030405060708090A0B0C0D0E0F
+#EXTINF:2,
+seg0.ts
+#EXT-X-KEY:METHOD=AES-128,URI="k",IV=0x1F
+#EXTINF:2,
+seg1.ts
+`
+	pl, err := ParseMedia(strings.NewReader(input), "")
+	if err != nil {
+		t.Fatalf("ParseMedia: %v", err)
+	}
+
+	want := []string{"000102030405060708090a0b0c0d0e0f", "0000000000000000000000000000001f"}
+	for i, seg := range pl.Segments {
+		if got := fmt.Sprintf("%x", seg.Key.IV); got != want[i] {
+			t.Errorf("segment %d IV = %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestDownloadDecryptsRotatingKeys(t *testing.T) {
+	srv := newTestServer(t)
+	key1 := []byte("0123456789abcdef")
+	key2 := []byte("fedcba9876543210")
+	iv := bytes.Repeat([]byte{0x42}, aes.BlockSize)
+	srv.set("/k1", key1)
+	srv.set("/k2", key2)
+
+	// Segments 10-12 use key 1 and the sequence as IV, 13-15 key 2 with
+	// an explicit IV
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:10\n")
+	b.WriteString("#EXT-X-KEY:METHOD=AES-128,URI=\"k1\"\n")
+	for i := range 6 {
+		if i == 3 {
+			fmt.Fprintf(&b, "#EXT-X-KEY:METHOD=AES-128,URI=\"k2\",IV=0x%x\n", iv)
+		}
+		key, segIV := key1, segmentIV(&Key{}, uint64(10+i))
+		if i >= 3 {
+			key, segIV = key2, iv
+		}
+		path := fmt.Sprintf("/seg%d.ts", i)
+		srv.set(path, encrypt(t, segmentData(i), key, segIV))
+		fmt.Fprintf(&b, "#EXTINF:2,\n%s\n", path[1:])
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+	pl := srv.parseMedia(t, b.String())
+
+	d := &Downloader{Workers: 4}
+	var out bytes.Buffer
+	if _, err := d.Download(context.Background(), pl, &out); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if want := allSegments(6); !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("output\n got %q\nwant %q", out.Bytes(), want)
+	}
+	for _, path := range []string{"/k1", "/k2"} {
+		if got := srv.hitsOf(path); got != 1 {
+			t.Errorf("key %s fetched %d times, want 1", path, got)
+		}
+	}
+}
+
+func TestKeyCacheFetchesEachURIOnce(t *testing.T) {
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	failFirst := true
+	cache := newKeyCache(func(ctx context.Context, uri string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches[uri]++
+		if uri == "flaky" && failFirst {
+			failFirst = false
+			return nil, errors.New("connection reset")
+		}
+		return []byte("0123456789abcdef"), nil
+	})
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.get(context.Background(), fmt.Sprintf("k%d", i%2)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// A failed key is fetched again on the next use
+	if _, err := cache.get(context.Background(), "flaky"); err == nil {
+		t.Error("first fetch of the flaky key succeeded")
+	}
+	if _, err := cache.get(context.Background(), "flaky"); err != nil {
+		t.Errorf("second fetch of the flaky key: %v", err)
+	}
+
+	want := map[string]int{"k0": 1, "k1": 1, "flaky": 2}
+	if fmt.Sprint(fetches) != fmt.Sprint(want) {
+		t.Errorf("fetches = %v, want %v", fetches, want)
+	}
+}
+
+func TestKeyCacheRejectsWrongKeySize(t *testing.T) {
+	cache := newKeyCache(func(ctx context.Context, uri string) ([]byte, error) {
+		return []byte("short"), nil
+	})
+	if _, err := cache.get(context.Background(), "k"); err == nil {
+		t.Error("a 5-byte key was accepted")
+	}
+}
+
+func TestDecryptSegmentErrors(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	iv := make([]byte, aes.BlockSize)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A block ending in 0 or in more than 16, or with mismatched pad bytes
+	badPads := [][]byte{
+		append(bytes.Repeat([]byte{'a'}, 15), 0),
+		append(bytes.Repeat([]byte{'a'}, 15), 17),
+		append(bytes.Repeat([]byte{'a'}, 13), 1, 3, 3),
+	}
+	for _, plain := range badPads {
+		data := make([]byte, len(plain))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, plain)
+		if _, err := decryptSegment(data, key, iv); !errors.Is(err, errBadPadding) {
+			t.Errorf("decrypting %q: error = %v, want errBadPadding", plain, err)
+		}
+	}
+
+	if _, err := decryptSegment(make([]byte, 20), key, iv); err == nil {
+		t.Error("a 20-byte segment was decrypted")
+	}
+
+	// The right key round-trips; a wrong one fails the padding check
+	data := encrypt(t, []byte("hello"), key, iv)
+	if plain, err := decryptSegment(data, key, iv); err != nil || string(plain) != "hello" {
+		t.Errorf("decryptSegment = %q, %v", plain, err)
+	}
+	if _, err := decryptSegment(data, []byte("fedcba9876543210"), iv); !errors.Is(err, errBadPadding) {
+		t.Errorf("wrong key: error = %v, want errBadPadding", err)
+	}
+}
+
+func TestCheckRejectsUnsupportedEncryption(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		ok   bool
+	}{
+		{name: "AES-128", key: `METHOD=AES-128,URI="k"`, ok: true},
+		{name: "identity key format", key: `METHOD=AES-128,URI="k",KEYFORMAT="identity"`, ok: true},
+		{name: "SAMPLE-AES", key: `METHOD=SAMPLE-AES,URI="k"`},
+		{name: "SAMPLE-AES-CTR", key: `METHOD=SAMPLE-AES-CTR,URI="k"`},
+		{name: "FairPlay", key: `METHOD=SAMPLE-AES,URI="skd://k",KEYFORMAT="com.apple.streamingkeydelivery"`},
+		{name: "Widevine", key: `METHOD=SAMPLE-AES-CTR,URI="data:text/plain;base64,AA",KEYFORMAT="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"`},
+		{name: "PlayReady", key: `METHOD=SAMPLE-AES-CTR,URI="k",KEYFORMAT="com.microsoft.playready"`},
+		{name: "unknown key format", key: `METHOD=AES-128,URI="k",KEYFORMAT="com.example.drm"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2,\nseg0.ts\n" +
+				"#EXT-X-KEY:" + tt.key + "\n#EXTINF:2,\nseg1.ts\n#EXT-X-ENDLIST\n"
+			pl, err := ParseMedia(strings.NewReader(input), "https://example.com/index.m3u8")
+			if err != nil {
+				t.Fatalf("ParseMedia: %v", err)
+			}
+
+			err = (&Downloader{}).Check(pl)
+			if tt.ok {
+				if err != nil {
+					t.Errorf("Check: %v", err)
+				}
+			} else if !errors.Is(err, ErrUnsupportedEncryption) {
+				t.Errorf("Check error = %v, want ErrUnsupportedEncryption", err)
+			}
+		})
+	}
+}
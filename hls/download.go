@@ -0,0 +1,507 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Downloader fetches the segments of a media playlist concurrently and
+// writes them to a single output in playlist order
+type Downloader struct {
+	// Client sends the segment requests; nil uses http.DefaultClient.
+	// Tests can point it at an httptest server.
+	Client *http.Client
+
+	// Workers is the number of segments fetched at once, at least 1
+	Workers int
+
+	// Retries is how many times a failed segment is fetched again;
+	// RetryDelay is the first wait between attempts and doubles each time
+	Retries    int
+	RetryDelay time.Duration
+
+	// PlaylistRetries is how many times a failed playlist request, a live
+	// poll included, is made again with the same backoff
+	PlaylistRetries int
+
+	// Timeout limits each segment request; 0 means no limit
+	Timeout time.Duration
+
+	// Header is sent with every request, e.g. a User-Agent or Referer
+	Header http.Header
+
+	// OnProgress is called after each segment is written and, while
+	// recording, after each playlist poll, on the goroutine that called
+	// Download or Record
+	OnProgress func(Progress)
+
+	// OnRetry is called from a worker before a failed segment is retried
+	OnRetry func(seg Segment, attempt int, err error)
+}
+
+// Progress describes how far a download has come
+type Progress struct {
+	Segment  int   // segments written so far
+	Segments int   // segments in the playlist
+	Bytes    int64 // bytes written so far
+	Duration time.Duration
+	Elapsed  time.Duration
+
+	// Fragmented is set when the segments are fMP4 with init sections
+	Fragmented bool
+
+	// Live recordings only: media listed in the latest playlist but not
+	// written yet, and segments that left the playlist before they
+	// could be fetched
+	Live   bool
+	Behind time.Duration
+	Missed int
+}
+
+// EstimatedTotal extrapolates the final size from the segments written
+func (p Progress) EstimatedTotal() int64 {
+	if p.Segment == 0 {
+		return 0
+	}
+	return p.Bytes * int64(p.Segments) / int64(p.Segment)
+}
+
+// Speed returns the average bytes per second so far
+func (p Progress) Speed() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Bytes) / p.Elapsed.Seconds()
+}
+
+// StatusError is an unexpected HTTP status for a playlist, key or segment
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+// Error renders the status code and URL
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP %d %s for %s", e.StatusCode, http.StatusText(e.StatusCode), e.URL)
+}
+
+// SegmentError reports the segment that failed after all retries
+type SegmentError struct {
+	Sequence uint64
+	URI      string
+	Attempts int
+	Err      error
+}
+
+// Error renders the failed segment and the last error
+func (e *SegmentError) Error() string {
+	return fmt.Sprintf("segment %d failed after %d attempt(s): %v", e.Sequence, e.Attempts, e.Err)
+}
+
+// Unwrap returns the last error of the segment
+func (e *SegmentError) Unwrap() error {
+	return e.Err
+}
+
+// maxRetryDelay caps the backoff between segment attempts
+const maxRetryDelay = 30 * time.Second
+
+// ErrUnsupported is returned for playlists the downloader cannot handle
+var ErrUnsupported = errors.New("unsupported playlist")
+
+// segmentResult is a fetched segment waiting to be written
+type segmentResult struct {
+	index int
+	data  []byte
+	err   error
+}
+
+// stream is the state of one output, shared by every batch of segments
+// written to it
+type stream struct {
+	w        io.Writer
+	keys     *keyCache
+	inits    map[string][]byte
+	initKey  string
+	started  time.Time
+	progress Progress
+}
+
+// newStream starts an output written to w
+func (d *Downloader) newStream(w io.Writer) *stream {
+	return &stream{
+		w:       w,
+		keys:    newKeyCache(d.get),
+		inits:   make(map[string][]byte),
+		started: time.Now(),
+	}
+}
+
+// Download writes every segment of playlist to w in order and returns
+// the number of bytes written. Segments are fetched by Workers
+// goroutines; at most twice that many are held in memory. AES-128
+// segments are decrypted on the way, byte ranges are fetched with HTTP
+// Range requests, and for fMP4 each init section is written before the
+// first segment that uses it.
+func (d *Downloader) Download(ctx context.Context, playlist *MediaPlaylist, w io.Writer) (int64, error) {
+	if err := d.Check(playlist); err != nil {
+		return 0, err
+	}
+
+	st := d.newStream(w)
+	st.progress.Segments = len(playlist.Segments)
+	st.progress.Fragmented = playlist.Fragmented()
+	err := d.writeSegments(ctx, st, playlist.Segments)
+	return st.progress.Bytes, err
+}
+
+// writeSegments fetches segments with the worker pool and writes them
+// to the stream in order
+func (d *Downloader) writeSegments(ctx context.Context, st *stream, segments []Segment) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := d.fetchInits(ctx, st, segments); err != nil {
+		return err
+	}
+
+	workers := max(d.Workers, 1)
+
+	// Tokens bound the segments fetched but not yet written; segments
+	// are handed out in order, so the next one to write always has one
+	tokens := make(chan struct{}, workers*2)
+	jobs := make(chan int)
+	results := make(chan segmentResult)
+
+	go func() {
+		defer close(jobs)
+		for i := range segments {
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				data, err := d.fetchSegment(ctx, segments[i], st.keys)
+				select {
+				case results <- segmentResult{index: i, data: data, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Write segments as soon as they are next in line
+	progress := &st.progress
+	waiting := make(map[int][]byte)
+	for next := 0; next < len(segments); {
+		var res segmentResult
+		select {
+		case r, ok := <-results:
+			if !ok {
+				return ctx.Err()
+			}
+			res = r
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if res.err != nil {
+			return res.err
+		}
+		waiting[res.index] = res.data
+
+		for data, ok := waiting[next]; ok; data, ok = waiting[next] {
+			seg := segments[next]
+
+			// The init section is repeated only when the map changes
+			if seg.Map != nil && mapKey(seg.Map) != st.initKey {
+				st.initKey = mapKey(seg.Map)
+				n, err := st.w.Write(st.inits[st.initKey])
+				progress.Bytes += int64(n)
+				if err != nil {
+					return err
+				}
+			}
+
+			n, err := st.w.Write(data)
+			progress.Bytes += int64(n)
+			if err != nil {
+				return err
+			}
+			delete(waiting, next)
+			<-tokens
+			next++
+
+			progress.Duration += seg.Duration
+			progress.Segment++
+			progress.Elapsed = time.Since(st.started)
+			if progress.Live {
+				progress.Behind = max(progress.Behind-seg.Duration, 0)
+			}
+			if d.OnProgress != nil {
+				d.OnProgress(*progress)
+			}
+		}
+	}
+	return nil
+}
+
+// Check rejects playlists that would not download correctly, before
+// anything is fetched
+func (d *Downloader) Check(playlist *MediaPlaylist) error {
+	if len(playlist.Segments) == 0 {
+		return fmt.Errorf("%w: no segments", ErrUnsupported)
+	}
+	fragmented := playlist.Fragmented()
+	for _, seg := range playlist.Segments {
+		if err := checkKey(seg.Key); err != nil {
+			return err
+		}
+		if seg.Map != nil {
+			if err := checkKey(seg.Map.Key); err != nil {
+				return err
+			}
+		}
+		// TS and fMP4 segments cannot share one output file
+		if (seg.Map != nil) != fragmented {
+			return fmt.Errorf("%w: mixed TS and fMP4 segments", ErrUnsupported)
+		}
+	}
+	return nil
+}
+
+// fetchSegment downloads and decrypts one segment, retrying it on its
+// own with exponential backoff
+func (d *Downloader) fetchSegment(ctx context.Context, seg Segment, keys *keyCache) ([]byte, error) {
+	delay := d.RetryDelay
+	attempts := d.Retries + 1
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		var data []byte
+		data, err = d.getRange(ctx, seg.URI, seg.ByteRange)
+		if err == nil && seg.Key != nil {
+			data, err = d.decrypt(ctx, seg, data, keys)
+		}
+		if err == nil {
+			return data, nil
+		}
+		if ctx.Err() != nil || !retryable(err) || attempt == attempts {
+			return nil, &SegmentError{Sequence: seg.Sequence, URI: seg.URI, Attempts: attempt, Err: err}
+		}
+
+		if d.OnRetry != nil {
+			d.OnRetry(seg, attempt+1, err)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+	return nil, err
+}
+
+// decrypt decrypts an AES-128 segment with its (cached) key; keys may
+// rotate at any segment
+func (d *Downloader) decrypt(ctx context.Context, seg Segment, data []byte, keys *keyCache) ([]byte, error) {
+	key, err := keys.get(ctx, seg.Key.URI)
+	if err != nil {
+		return nil, err
+	}
+	return decryptSegment(data, key, segmentIV(seg.Key, seg.Sequence))
+}
+
+// fetchInits fetches the init section of every EXT-X-MAP the stream
+// has not seen yet, before any of the segments
+func (d *Downloader) fetchInits(ctx context.Context, st *stream, segments []Segment) error {
+	for _, seg := range segments {
+		if seg.Map == nil {
+			continue
+		}
+		key := mapKey(seg.Map)
+		if _, ok := st.inits[key]; ok {
+			continue
+		}
+		data, err := d.fetchSegment(ctx, initSegment(seg), st.keys)
+		if err != nil {
+			return err
+		}
+		st.inits[key] = data
+	}
+	return nil
+}
+
+// initSegment describes the init section of seg as a segment, so it is
+// fetched, retried and decrypted the same way. An encrypted init
+// section has an explicit IV; otherwise the IV of seg is used.
+func initSegment(seg Segment) Segment {
+	return Segment{URI: seg.Map.URI, Sequence: seg.Sequence, ByteRange: seg.Map.ByteRange, Key: seg.Map.Key}
+}
+
+// mapKey identifies an init section by its URI and byte range, so a
+// map repeated by a later tag is not fetched again
+func mapKey(m *Map) string {
+	if m.ByteRange == nil {
+		return m.URI
+	}
+	return fmt.Sprintf("%s@%d-%d", m.URI, m.ByteRange.Offset, m.ByteRange.End())
+}
+
+// get fetches a whole resource
+func (d *Downloader) get(ctx context.Context, uri string) ([]byte, error) {
+	return d.getRange(ctx, uri, nil)
+}
+
+// getRange fetches a resource, or only the byte range br of it when br
+// is not nil. Servers that ignore the Range header and send the whole
+// resource are tolerated.
+func (d *Downloader) getRange(ctx context.Context, uri string, br *ByteRange) ([]byte, error) {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range d.Header {
+		req.Header[name] = values
+	}
+	if br != nil {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", br.Offset, br.End()-1))
+	}
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	partial := br != nil && resp.StatusCode == http.StatusPartialContent
+	if resp.StatusCode != http.StatusOK && !partial {
+		return nil, &StatusError{URL: uri, StatusCode: resp.StatusCode}
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil || br == nil {
+		return data, err
+	}
+
+	// A 200 carries the whole resource, from which the range is cut
+	if !partial {
+		if int64(len(data)) < br.End() {
+			return nil, fmt.Errorf("%s is %d bytes, shorter than range %d-%d", uri, len(data), br.Offset, br.End()-1)
+		}
+		return data[br.Offset:br.End()], nil
+	}
+	if int64(len(data)) != br.Length {
+		return nil, fmt.Errorf("%s returned %d bytes for a %d-byte range", uri, len(data), br.Length)
+	}
+	return data, nil
+}
+
+// retryable reports whether a failed request may succeed when repeated;
+// client errors other than timeouts and rate limits are final
+func retryable(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		code := status.StatusCode
+		return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// Fetch downloads and parses the playlist at playlistURL with the
+// downloader's client, headers and timeout
+func (d *Downloader) Fetch(ctx context.Context, playlistURL string) (Playlist, error) {
+	data, err := d.get(ctx, playlistURL)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(bytes.NewReader(data), playlistURL)
+}
+
+// fetchRetrying fetches a playlist, retrying failed requests up to
+// PlaylistRetries times with the backoff of failed segments
+func (d *Downloader) fetchRetrying(ctx context.Context, playlistURL string) (Playlist, error) {
+	delay := d.RetryDelay
+	for attempt := 1; ; attempt++ {
+		pl, err := d.Fetch(ctx, playlistURL)
+		if err == nil {
+			return pl, nil
+		}
+		if ctx.Err() != nil || !retryable(err) || attempt > d.PlaylistRetries {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// FetchMedia fetches the media playlist at playlistURL; for a master
+// playlist it follows the variant with the highest bandwidth, which is
+// returned as well. A variant whose audio is a separate rendition is
+// rejected, since only one stream is downloaded.
+func (d *Downloader) FetchMedia(ctx context.Context, playlistURL string) (*MediaPlaylist, *Variant, error) {
+	pl, err := d.fetchRetrying(ctx, playlistURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch pl := pl.(type) {
+	case *MediaPlaylist:
+		return pl, nil, nil
+	case *MasterPlaylist:
+		variant := pl.Best()
+		if variant == nil {
+			return nil, nil, fmt.Errorf("%w: master playlist has no regular variants", ErrUnsupported)
+		}
+		if audio := pl.SeparateAudio(variant); audio != nil {
+			return nil, nil, fmt.Errorf("%w: the audio of variant %s is a separate rendition (%s)", ErrUnsupported, variant.URI, audio.URI)
+		}
+		media, err := d.fetchRetrying(ctx, variant.URI)
+		if err != nil {
+			return nil, nil, err
+		}
+		if m, ok := media.(*MediaPlaylist); ok {
+			return m, variant, nil
+		}
+		return nil, nil, fmt.Errorf("%w: variant %s is not a media playlist", ErrUnsupported, variant.URI)
+	}
+	return nil, nil, fmt.Errorf("%w: unknown playlist kind", ErrUnsupported)
+}
@@ -0,0 +1,486 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer serves files from memory and records the requests it got
+type testServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	files       map[string][]byte
+	fail        map[string][]int // statuses answered, in turn, before a file is served
+	delay       map[string]time.Duration
+	hits        map[string]int
+	ranges      map[string][]string // Range headers received
+	ignoreRange bool
+}
+
+// newTestServer starts a server that is closed when the test ends
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{
+		files:  make(map[string][]byte),
+		fail:   make(map[string][]int),
+		delay:  make(map[string]time.Duration),
+		hits:   make(map[string]int),
+		ranges: make(map[string][]string),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// serve answers one request
+func (s *testServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	path := r.URL.Path
+	s.hits[path]++
+	if rng := r.Header.Get("Range"); rng != "" {
+		s.ranges[path] = append(s.ranges[path], rng)
+	}
+	status := 0
+	if codes := s.fail[path]; len(codes) > 0 {
+		status, s.fail[path] = codes[0], codes[1:]
+	}
+	data, ok := s.files[path]
+	delay := s.delay[path]
+	ignoreRange := s.ignoreRange
+	s.mu.Unlock()
+
+	time.Sleep(delay)
+	switch {
+	case status != 0:
+		w.WriteHeader(status)
+	case !ok:
+		http.NotFound(w, r)
+	case ignoreRange:
+		w.Write(data)
+	default:
+		http.ServeContent(w, r, path, time.Time{}, bytes.NewReader(data))
+	}
+}
+
+// set serves data at path
+func (s *testServer) set(path string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path] = data
+}
+
+// hitsOf returns how many requests path got
+func (s *testServer) hitsOf(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[path]
+}
+
+// segmentData is the content served for segment i
+func segmentData(i int) []byte {
+	return []byte(fmt.Sprintf("<segment %d>", i))
+}
+
+// addSegments serves n segments and returns a VOD playlist listing them
+func (s *testServer) addSegments(n int) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-TARGETDURATION:2\n")
+	for i := range n {
+		path := fmt.Sprintf("/seg%d.ts", i)
+		s.set(path, segmentData(i))
+		fmt.Fprintf(&b, "#EXTINF:2,\n%s\n", path[1:])
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String()
+}
+
+// parseMedia parses a media playlist as if served at /index.m3u8
+func (s *testServer) parseMedia(t *testing.T, text string) *MediaPlaylist {
+	t.Helper()
+	pl, err := ParseMedia(strings.NewReader(text), s.URL+"/index.m3u8")
+	if err != nil {
+		t.Fatalf("ParseMedia: %v", err)
+	}
+	return pl
+}
+
+// allSegments is the output expected for segments 0 to n-1
+func allSegments(n int) []byte {
+	var out []byte
+	for i := range n {
+		out = append(out, segmentData(i)...)
+	}
+	return out
+}
+
+func TestDownloadKeepsOrderWithWorkers(t *testing.T) {
+	srv := newTestServer(t)
+	const n = 12
+	pl := srv.parseMedia(t, srv.addSegments(n))
+	// Earlier segments answer later, so they complete out of order
+	for i := range n {
+		srv.delay[fmt.Sprintf("/seg%d.ts", i)] = time.Duration(n-i) * 3 * time.Millisecond
+	}
+
+	var progress []Progress
+	d := &Downloader{Workers: 4, OnProgress: func(p Progress) { progress = append(progress, p) }}
+	var out bytes.Buffer
+	written, err := d.Download(context.Background(), pl, &out)
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+
+	if want := allSegments(n); !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("output\n got %q\nwant %q", out.Bytes(), want)
+	}
+	if written != int64(out.Len()) {
+		t.Errorf("Download returned %d bytes, wrote %d", written, out.Len())
+	}
+
+	if len(progress) != n {
+		t.Fatalf("OnProgress called %d times, want %d", len(progress), n)
+	}
+	var bytesSoFar int64
+	for i, p := range progress {
+		bytesSoFar += int64(len(segmentData(i)))
+		if p.Segment != i+1 || p.Segments != n || p.Bytes != bytesSoFar || p.Duration != time.Duration(i+1)*2*time.Second {
+			t.Errorf("progress %d = %+v", i, p)
+		}
+	}
+}
+
+func TestDownloadRetriesOnlyTheFailedSegment(t *testing.T) {
+	srv := newTestServer(t)
+	pl := srv.parseMedia(t, srv.addSegments(5))
+	srv.fail["/seg2.ts"] = []int{http.StatusServiceUnavailable, http.StatusBadGateway}
+
+	var retries []int
+	d := &Downloader{
+		Workers:    2,
+		Retries:    3,
+		RetryDelay: time.Millisecond,
+		OnRetry: func(seg Segment, attempt int, err error) {
+			if seg.Sequence == 2 {
+				retries = append(retries, attempt)
+			}
+		},
+	}
+	var out bytes.Buffer
+	if _, err := d.Download(context.Background(), pl, &out); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+
+	if !bytes.Equal(out.Bytes(), allSegments(5)) {
+		t.Errorf("output = %q", out.Bytes())
+	}
+	for i := range 5 {
+		want := 1
+		if i == 2 {
+			want = 3
+		}
+		if got := srv.hitsOf(fmt.Sprintf("/seg%d.ts", i)); got != want {
+			t.Errorf("segment %d fetched %d times, want %d", i, got, want)
+		}
+	}
+	if fmt.Sprint(retries) != "[2 3]" {
+		t.Errorf("OnRetry attempts = %v, want [2 3]", retries)
+	}
+}
+
+func TestDownloadFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		retries  int
+		attempts int
+	}{
+		{name: "not found is final", status: http.StatusNotFound, retries: 3, attempts: 1},
+		{name: "forbidden is final", status: http.StatusForbidden, retries: 3, attempts: 1},
+		{name: "server errors use every retry", status: http.StatusInternalServerError, retries: 2, attempts: 3},
+		{name: "rate limits are retried", status: http.StatusTooManyRequests, retries: 1, attempts: 2},
+		{name: "no retries", status: http.StatusServiceUnavailable, retries: 0, attempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t)
+			pl := srv.parseMedia(t, srv.addSegments(3))
+			srv.fail["/seg1.ts"] = []int{tt.status, tt.status, tt.status, tt.status}
+
+			d := &Downloader{Retries: tt.retries, RetryDelay: time.Millisecond}
+			_, err := d.Download(context.Background(), pl, &bytes.Buffer{})
+
+			var segErr *SegmentError
+			if !errors.As(err, &segErr) {
+				t.Fatalf("Download error = %v, want a *SegmentError", err)
+			}
+			if segErr.Sequence != 1 || segErr.Attempts != tt.attempts {
+				t.Errorf("segment error = %+v, want sequence 1 after %d attempts", segErr, tt.attempts)
+			}
+			var status *StatusError
+			if !errors.As(err, &status) || status.StatusCode != tt.status {
+				t.Errorf("Download error = %v, want HTTP %d", err, tt.status)
+			}
+			if got := srv.hitsOf("/seg1.ts"); got != tt.attempts {
+				t.Errorf("segment fetched %d times, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestDownloadSendsHeader(t *testing.T) {
+	srv := newTestServer(t)
+	var agents []string
+	var mu sync.Mutex
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		agents = append(agents, r.UserAgent())
+		mu.Unlock()
+		srv.serve(w, r)
+	})
+	pl := srv.parseMedia(t, srv.addSegments(2))
+
+	d := &Downloader{Header: http.Header{"User-Agent": {"hls-test"}}}
+	if _, err := d.Download(context.Background(), pl, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if fmt.Sprint(agents) != "[hls-test hls-test]" {
+		t.Errorf("user agents = %v", agents)
+	}
+}
+
+func TestFetchMediaFollowsBestVariant(t *testing.T) {
+	srv := newTestServer(t)
+	srv.set("/master.m3u8", []byte(`#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2400000,RESOLUTION=1280x720
+hd/index.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=9000000,URI="iframes.m3u8"
+`))
+	srv.set("/hd/index.m3u8", []byte("#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2,\nseg0.ts\n#EXT-X-ENDLIST\n"))
+
+	d := &Downloader{}
+	media, variant, err := d.FetchMedia(context.Background(), srv.URL+"/master.m3u8")
+	if err != nil {
+		t.Fatalf("FetchMedia: %v", err)
+	}
+	if variant == nil || variant.Bandwidth != 2400000 {
+		t.Fatalf("variant = %+v, want the 2400000 one", variant)
+	}
+	if got, want := media.Segments[0].URI, srv.URL+"/hd/seg0.ts"; got != want {
+		t.Errorf("segment URI = %q, want %q", got, want)
+	}
+	if srv.hitsOf("/low/index.m3u8") != 0 {
+		t.Error("the lower variant was fetched")
+	}
+}
+
+func TestFetchMediaRetriesPlaylist(t *testing.T) {
+	srv := newTestServer(t)
+	srv.set("/index.m3u8", []byte("#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2,\nseg0.ts\n#EXT-X-ENDLIST\n"))
+	srv.fail["/index.m3u8"] = []int{http.StatusBadGateway, http.StatusServiceUnavailable}
+
+	d := &Downloader{PlaylistRetries: 2, RetryDelay: time.Millisecond}
+	if _, _, err := d.FetchMedia(context.Background(), srv.URL+"/index.m3u8"); err != nil {
+		t.Fatalf("FetchMedia: %v", err)
+	}
+	if got := srv.hitsOf("/index.m3u8"); got != 3 {
+		t.Errorf("playlist fetched %d times, want 3", got)
+	}
+
+	srv.fail["/index.m3u8"] = []int{http.StatusBadGateway}
+	d.PlaylistRetries = 0
+	var status *StatusError
+	if _, _, err := d.FetchMedia(context.Background(), srv.URL+"/index.m3u8"); !errors.As(err, &status) {
+		t.Errorf("FetchMedia error = %v, want the 502 without retries", err)
+	}
+}
+
+func TestFetchMediaRejectsSeparateAudio(t *testing.T) {
+	tests := []struct {
+		name   string
+		master string
+		reject bool
+	}{
+		{
+			name: "audio rendition with a URI",
+			master: `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English",DEFAULT=YES,URI="audio/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=2400000,AUDIO="aud"
+hd/index.m3u8
+`,
+			reject: true,
+		},
+		{
+			name: "muxed audio rendition",
+			master: `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English",DEFAULT=YES
+#EXT-X-STREAM-INF:BANDWIDTH=2400000,AUDIO="aud"
+hd/index.m3u8
+`,
+		},
+		{
+			name: "separate audio of another variant",
+			master: `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English",URI="audio/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=800000,AUDIO="aud"
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2400000
+hd/index.m3u8
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t)
+			srv.set("/master.m3u8", []byte(tt.master))
+			srv.set("/hd/index.m3u8", []byte("#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2,\nseg0.ts\n#EXT-X-ENDLIST\n"))
+
+			d := &Downloader{}
+			_, _, err := d.FetchMedia(context.Background(), srv.URL+"/master.m3u8")
+			if tt.reject {
+				if !errors.Is(err, ErrUnsupported) {
+					t.Errorf("FetchMedia error = %v, want ErrUnsupported", err)
+				}
+				if srv.hitsOf("/hd/index.m3u8") != 0 {
+					t.Error("the rejected variant was fetched")
+				}
+			} else if err != nil {
+				t.Errorf("FetchMedia: %v", err)
+			}
+		})
+	}
+}
+
+func TestDownloadWritesInitSectionsOncePerMap(t *testing.T) {
+	srv := newTestServer(t)
+	srv.set("/init1.mp4", []byte("<init 1>"))
+	srv.set("/inits.mp4", []byte("<init 2><init 3>"))
+	for i := range 7 {
+		srv.set(fmt.Sprintf("/seg%d.m4s", i), segmentData(i))
+	}
+	pl := srv.parseMedia(t, `#EXTM3U
+#EXT-X-TARGETDURATION:2
+#EXT-X-MAP:URI="init1.mp4"
+#EXTINF:2,
+seg0.m4s
+#EXTINF:2,
+seg1.m4s
+#EXT-X-MAP:URI="inits.mp4",BYTERANGE="8@0"
+#EXTINF:2,
+seg2.m4s
+#EXT-X-MAP:URI="inits.mp4",BYTERANGE="8@0"
+#EXTINF:2,
+seg3.m4s
+#EXT-X-MAP:URI="inits.mp4",BYTERANGE="8@8"
+#EXTINF:2,
+seg4.m4s
+#EXT-X-MAP:URI="init1.mp4"
+#EXTINF:2,
+seg5.m4s
+#EXTINF:2,
+seg6.m4s
+#EXT-X-ENDLIST
+`)
+
+	d := &Downloader{Workers: 3}
+	var out bytes.Buffer
+	if _, err := d.Download(context.Background(), pl, &out); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+
+	// A repeated tag for the same map writes nothing; going back to an
+	// earlier map writes it again
+	want := "<init 1><segment 0><segment 1><init 2><segment 2><segment 3>" +
+		"<init 3><segment 4><init 1><segment 5><segment 6>"
+	if out.String() != want {
+		t.Errorf("output\n got %s\nwant %s", out.String(), want)
+	}
+	if got := srv.hitsOf("/init1.mp4"); got != 1 {
+		t.Errorf("init1.mp4 fetched %d times, want 1", got)
+	}
+	if got := srv.hitsOf("/inits.mp4"); got != 2 {
+		t.Errorf("inits.mp4 fetched %d times, want once per range", got)
+	}
+}
+
+func TestDownloadByteRanges(t *testing.T) {
+	for _, ignoreRange := range []bool{false, true} {
+		t.Run(fmt.Sprintf("server ignores Range %v", ignoreRange), func(t *testing.T) {
+			srv := newTestServer(t)
+			srv.ignoreRange = ignoreRange
+			srv.set("/all.ts", allSegments(3))
+			pl := srv.parseMedia(t, fmt.Sprintf(`#EXTM3U
+#EXT-X-TARGETDURATION:2
+#EXTINF:2,
+#EXT-X-BYTERANGE:%d@0
+all.ts
+#EXTINF:2,
+#EXT-X-BYTERANGE:%d
+all.ts
+#EXTINF:2,
+#EXT-X-BYTERANGE:%d
+all.ts
+#EXT-X-ENDLIST
+`, len(segmentData(0)), len(segmentData(1)), len(segmentData(2))))
+
+			d := &Downloader{Workers: 1}
+			var out bytes.Buffer
+			if _, err := d.Download(context.Background(), pl, &out); err != nil {
+				t.Fatalf("Download: %v", err)
+			}
+			if want := allSegments(3); !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("output\n got %q\nwant %q", out.Bytes(), want)
+			}
+
+			// Each range header matches the playlist, inclusive of its end
+			var want []string
+			for _, seg := range pl.Segments {
+				want = append(want, fmt.Sprintf("bytes=%d-%d", seg.ByteRange.Offset, seg.ByteRange.End()-1))
+			}
+			if got := srv.ranges["/all.ts"]; fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("Range headers = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestDownloadRangePastEnd(t *testing.T) {
+	srv := newTestServer(t)
+	srv.ignoreRange = true
+	srv.set("/all.ts", []byte("short"))
+	pl := srv.parseMedia(t, "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2,\n#EXT-X-BYTERANGE:100@0\nall.ts\n#EXT-X-ENDLIST\n")
+
+	d := &Downloader{}
+	if _, err := d.Download(context.Background(), pl, &bytes.Buffer{}); err == nil {
+		t.Error("a range past the end of the resource was accepted")
+	}
+}
+
+func TestCheckRejectsMixedSegments(t *testing.T) {
+	pl, err := ParseMedia(strings.NewReader(`#EXTM3U
+#EXT-X-TARGETDURATION:2
+#EXTINF:2,
+seg0.ts
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:2,
+seg1.m4s
+#EXT-X-ENDLIST
+`), "https://example.com/index.m3u8")
+	if err != nil {
+		t.Fatalf("ParseMedia: %v", err)
+	}
+	if err := (&Downloader{}).Check(pl); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Check error = %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,196 @@
+package hls
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// liveEdgeSegments is how many segments from the end a recording from
+// the live edge starts; RFC 8216 advises against starting closer than
+// three target durations
+const liveEdgeSegments = 3
+
+// LiveOptions controls a live recording
+type LiveOptions struct {
+	// FromStart begins at the oldest segment still in the playlist (the
+	// start of the DVR window) instead of the live edge
+	FromStart bool
+
+	// MaxDuration stops the recording once this much media is written;
+	// 0 records until the stream ends
+	MaxDuration time.Duration
+
+	// Stop ends the recording when closed; segments already written
+	// are kept and Record returns without an error
+	Stop <-chan struct{}
+}
+
+// Record writes a live stream to w by re-polling the media playlist at
+// its target duration, writing each new segment once by media sequence.
+// It returns when the playlist ends, MaxDuration is reached or Stop is
+// closed. A playlist that has already ended is written from its start,
+// and one that lists no segments yet is polled until the stream begins.
+func (d *Downloader) Record(ctx context.Context, playlist *MediaPlaylist, w io.Writer, opts LiveOptions) (int64, error) {
+	if len(playlist.Segments) > 0 || !playlist.Live() {
+		if err := d.Check(playlist); err != nil {
+			return 0, err
+		}
+	}
+
+	// Stop cancels whatever is in flight; completed segments stay written
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-opts.Stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	stopped := func() bool {
+		select {
+		case <-opts.Stop:
+			return true
+		default:
+			return false
+		}
+	}
+
+	st := d.newStream(w)
+	st.progress.Live = true
+
+	// next is the media sequence of the first segment not written yet,
+	// known once the playlist lists segments; a stream that began while
+	// we waited is recorded from its first segment. oldest is the first
+	// sequence of the latest playlist that listed any.
+	var next, oldest uint64
+	var started, fragmented bool
+	waited := len(playlist.Segments) == 0
+	polled := time.Now()
+	for {
+		if !started && len(playlist.Segments) > 0 {
+			started = true
+			fragmented = playlist.Fragmented()
+			st.progress.Fragmented = fragmented
+			next = playlist.Segments[0].Sequence
+			if playlist.Live() && !opts.FromStart && !waited {
+				next = playlist.Segments[max(len(playlist.Segments)-liveEdgeSegments, 0)].Sequence
+			}
+		}
+		if len(playlist.Segments) > 0 {
+			oldest = playlist.Segments[0].Sequence
+		}
+
+		batch := newSegments(playlist, next)
+		if len(batch) > 0 && batch[0].Sequence > next {
+			st.progress.Missed += int(batch[0].Sequence - next)
+		}
+
+		// Drop the segments past the duration limit; the last one kept
+		// may end a little after it
+		done := !playlist.Live()
+		if opts.MaxDuration > 0 {
+			recorded := st.progress.Duration
+			for i, seg := range batch {
+				if recorded >= opts.MaxDuration {
+					batch = batch[:i]
+					done = true
+					break
+				}
+				recorded += seg.Duration
+			}
+		}
+
+		st.progress.Segments += len(batch)
+		st.progress.Behind = 0
+		for _, seg := range batch {
+			st.progress.Behind += seg.Duration
+		}
+		if d.OnProgress != nil {
+			d.OnProgress(st.progress)
+		}
+
+		if err := d.writeSegments(ctx, st, batch); err != nil {
+			if stopped() {
+				return st.progress.Bytes, nil
+			}
+			return st.progress.Bytes, err
+		}
+		if len(batch) > 0 {
+			next = batch[len(batch)-1].Sequence + 1
+		}
+		if opts.MaxDuration > 0 && st.progress.Duration >= opts.MaxDuration {
+			done = true
+		}
+		if done {
+			return st.progress.Bytes, nil
+		}
+
+		// Poll again a target duration after the previous poll, or half
+		// of one when the playlist had not changed (RFC 8216 6.3.4)
+		wait := playlist.TargetDuration
+		if len(batch) == 0 {
+			wait /= 2
+		}
+		select {
+		case <-time.After(time.Until(polled.Add(wait))):
+		case <-ctx.Done():
+			if stopped() {
+				return st.progress.Bytes, nil
+			}
+			return st.progress.Bytes, ctx.Err()
+		}
+
+		polled = time.Now()
+		updated, err := d.refresh(ctx, playlist.URL)
+		if err != nil {
+			if stopped() {
+				return st.progress.Bytes, nil
+			}
+			return st.progress.Bytes, err
+		}
+		// A playlist emptied for a moment, e.g. while the encoder
+		// restarts, just has no new segments yet
+		if len(updated.Segments) > 0 {
+			if err := d.Check(updated); err != nil {
+				return st.progress.Bytes, err
+			}
+			// TS and fMP4 segments cannot share one output file
+			if started && updated.Fragmented() != fragmented {
+				return st.progress.Bytes, fmt.Errorf("%w: the stream switched between TS and fMP4 segments", ErrUnsupported)
+			}
+			// A restarted encoder numbers its segments from the start
+			// again. A playlist entirely older than the last one seen is
+			// that rather than a lagging copy, and its segments are new.
+			if started && updated.Segments[len(updated.Segments)-1].Sequence < oldest {
+				next = updated.Segments[0].Sequence
+			}
+		}
+		playlist = updated
+	}
+}
+
+// newSegments returns the segments of playlist from media sequence next
+// on; earlier ones were already written
+func newSegments(playlist *MediaPlaylist, next uint64) []Segment {
+	for i, seg := range playlist.Segments {
+		if seg.Sequence >= next {
+			return playlist.Segments[i:]
+		}
+	}
+	return nil
+}
+
+// refresh fetches the media playlist again
+func (d *Downloader) refresh(ctx context.Context, playlistURL string) (*MediaPlaylist, error) {
+	pl, err := d.fetchRetrying(ctx, playlistURL)
+	if err != nil {
+		return nil, err
+	}
+	if media, ok := pl.(*MediaPlaylist); ok {
+		return media, nil
+	}
+	return nil, fmt.Errorf("%w: %s is no longer a media playlist", ErrUnsupported, playlistURL)
+}
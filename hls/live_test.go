@@ -0,0 +1,286 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// window is one version of a live playlist: segments first to last, or
+// none when last < first
+type window struct {
+	first, last uint64
+	ended       bool
+}
+
+// playlist renders the window with one-second segments. A zero target
+// duration makes Record poll again right away.
+func (w window) playlist() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#EXTM3U\n#EXT-X-TARGETDURATION:0\n#EXT-X-MEDIA-SEQUENCE:%d\n", w.first)
+	for seq := w.first; seq <= w.last && w.last >= w.first; seq++ {
+		fmt.Fprintf(&b, "#EXTINF:1,\nseg%d.ts\n", seq)
+	}
+	if w.ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return b.String()
+}
+
+// liveServer serves segments 0 to 99 and a live playlist that moves to
+// the next of polls on every request, staying at the last one
+func liveServer(t *testing.T, polls ...window) *testServer {
+	srv := newTestServer(t)
+	for seq := range 100 {
+		srv.set(fmt.Sprintf("/seg%d.ts", seq), segmentData(seq))
+	}
+
+	var mu sync.Mutex
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/index.m3u8" {
+			mu.Lock()
+			srv.set("/index.m3u8", []byte(polls[0].playlist()))
+			if len(polls) > 1 {
+				polls = polls[1:]
+			}
+			mu.Unlock()
+		}
+		srv.serve(w, r)
+	})
+	return srv
+}
+
+// segmentsFrom is the output expected for the given sequences
+func segmentsFrom(seqs ...int) []byte {
+	var out []byte
+	for _, seq := range seqs {
+		out = append(out, segmentData(seq)...)
+	}
+	return out
+}
+
+// span lists the sequences first to last
+func span(first, last int) []int {
+	var seqs []int
+	for seq := first; seq <= last; seq++ {
+		seqs = append(seqs, seq)
+	}
+	return seqs
+}
+
+func TestRecord(t *testing.T) {
+	tests := []struct {
+		name   string
+		start  window
+		polls  []window
+		opts   LiveOptions
+		want   []int
+		missed int
+	}{
+		{
+			name:  "starts at the live edge",
+			start: window{0, 5, false},
+			polls: []window{{2, 7, false}, {2, 7, false}, {4, 9, true}},
+			want:  span(3, 9),
+		},
+		{
+			name:  "from the DVR start",
+			start: window{0, 5, false},
+			polls: []window{{2, 7, false}, {2, 7, false}, {4, 9, true}},
+			opts:  LiveOptions{FromStart: true},
+			want:  span(0, 9),
+		},
+		{
+			name:  "short window",
+			start: window{0, 1, false},
+			polls: []window{{0, 2, true}},
+			want:  span(0, 2),
+		},
+		{
+			name:   "segments that slid out are missed",
+			start:  window{0, 5, false},
+			polls:  []window{{10, 12, true}},
+			want:   append(span(3, 5), span(10, 12)...),
+			missed: 4,
+		},
+		{
+			name:  "empty refresh keeps polling",
+			start: window{0, 2, false},
+			polls: []window{{3, 2, false}, {3, 4, true}},
+			want:  span(0, 4),
+		},
+		{
+			name:  "empty at start waits for the stream",
+			start: window{3, 2, false},
+			polls: []window{{3, 2, false}, {0, 4, true}},
+			want:  span(0, 4),
+		},
+		{
+			name:  "encoder restart resyncs",
+			start: window{50, 55, false},
+			polls: []window{{50, 56, false}, {0, 2, true}},
+			want:  append(span(53, 56), span(0, 2)...),
+		},
+		{
+			name:  "lagging copy is not a restart",
+			start: window{10, 15, false},
+			polls: []window{{9, 14, false}, {11, 17, true}},
+			want:  span(13, 17),
+		},
+		{
+			name:  "ended playlist from its start",
+			start: window{0, 5, true},
+			want:  span(0, 5),
+		},
+		{
+			name:  "maximum duration across polls",
+			start: window{0, 1, false},
+			polls: []window{{0, 8, false}},
+			opts:  LiveOptions{FromStart: true, MaxDuration: 4500 * time.Millisecond},
+			want:  span(0, 4),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A final ended window keeps a broken Record from polling forever
+			srv := liveServer(t, append(tt.polls, window{0, 0, true})...)
+			pl := srv.parseMedia(t, tt.start.playlist())
+
+			var last Progress
+			d := &Downloader{Workers: 2, OnProgress: func(p Progress) { last = p }}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			var out bytes.Buffer
+			written, err := d.Record(ctx, pl, &out, tt.opts)
+			if err != nil {
+				t.Fatalf("Record: %v", err)
+			}
+
+			if want := segmentsFrom(tt.want...); !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("output\n got %s\nwant %s", out.Bytes(), want)
+			}
+			if written != int64(out.Len()) {
+				t.Errorf("Record returned %d bytes, wrote %d", written, out.Len())
+			}
+			for _, seq := range tt.want {
+				if got := srv.hitsOf(fmt.Sprintf("/seg%d.ts", seq)); got != 1 {
+					t.Errorf("segment %d fetched %d times, want 1", seq, got)
+				}
+			}
+			if !last.Live || last.Segment != len(tt.want) || last.Missed != tt.missed {
+				t.Errorf("last progress = %+v, want %d segments and %d missed", last, len(tt.want), tt.missed)
+			}
+		})
+	}
+}
+
+func TestRecordMaxDurationStopsPolling(t *testing.T) {
+	srv := liveServer(t, window{0, 9, false})
+	pl := srv.parseMedia(t, window{0, 5, false}.playlist())
+
+	d := &Downloader{}
+	var out bytes.Buffer
+	if _, err := d.Record(context.Background(), pl, &out, LiveOptions{FromStart: true, MaxDuration: 3 * time.Second}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if want := segmentsFrom(span(0, 2)...); !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("output\n got %s\nwant %s", out.Bytes(), want)
+	}
+	if got := srv.hitsOf("/index.m3u8"); got != 0 {
+		t.Errorf("playlist polled %d times after the limit was reached", got)
+	}
+}
+
+func TestRecordStop(t *testing.T) {
+	// The playlist never ends
+	srv := liveServer(t, window{0, 2, false})
+	pl := srv.parseMedia(t, window{0, 2, false}.playlist())
+
+	stop := make(chan struct{})
+	var once sync.Once
+	d := &Downloader{OnProgress: func(p Progress) {
+		if p.Segment == 3 {
+			once.Do(func() { close(stop) })
+		}
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var out bytes.Buffer
+	if _, err := d.Record(ctx, pl, &out, LiveOptions{Stop: stop}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if want := segmentsFrom(span(0, 2)...); !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("output\n got %s\nwant %s", out.Bytes(), want)
+	}
+	if ctx.Err() != nil {
+		t.Error("Record ran until the test timeout")
+	}
+}
+
+func TestRecordPollFailure(t *testing.T) {
+	srv := liveServer(t, window{0, 2, false})
+	pl := srv.parseMedia(t, window{0, 2, false}.playlist())
+	srv.fail["/index.m3u8"] = []int{http.StatusServiceUnavailable, http.StatusNotFound}
+
+	d := &Downloader{PlaylistRetries: 3, RetryDelay: time.Millisecond}
+	var out bytes.Buffer
+	_, err := d.Record(context.Background(), pl, &out, LiveOptions{})
+	var status *StatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusNotFound {
+		t.Errorf("Record error = %v, want the 404 after one retry", err)
+	}
+	if got := srv.hitsOf("/index.m3u8"); got != 2 {
+		t.Errorf("playlist fetched %d times, want 2", got)
+	}
+	if want := segmentsFrom(span(0, 2)...); !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("segments written before the failure\n got %s\nwant %s", out.Bytes(), want)
+	}
+}
+
+func TestRecordEndedEmpty(t *testing.T) {
+	srv := liveServer(t, window{0, 0, true})
+	pl := srv.parseMedia(t, window{3, 2, true}.playlist())
+
+	var out bytes.Buffer
+	if _, err := (&Downloader{}).Record(context.Background(), pl, &out, LiveOptions{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Record error = %v, want ErrUnsupported", err)
+	}
+	if got := srv.hitsOf("/index.m3u8"); got != 0 {
+		t.Errorf("playlist polled %d times", got)
+	}
+}
+
+func TestRecordFormatSwitch(t *testing.T) {
+	srv := newTestServer(t)
+	for seq := range 6 {
+		srv.set(fmt.Sprintf("/seg%d.ts", seq), segmentData(seq))
+	}
+	srv.set("/init.mp4", []byte("<init>"))
+	srv.set("/index.m3u8", []byte(`#EXTM3U
+#EXT-X-TARGETDURATION:0
+#EXT-X-MEDIA-SEQUENCE:3
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:1,
+seg3.ts
+#EXTINF:1,
+seg4.ts
+`))
+	pl := srv.parseMedia(t, window{0, 2, false}.playlist())
+
+	var out bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := (&Downloader{}).Record(ctx, pl, &out, LiveOptions{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Record error = %v, want ErrUnsupported", err)
+	}
+	if want := segmentsFrom(span(0, 2)...); !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("segments written before the switch\n got %s\nwant %s", out.Bytes(), want)
+	}
+}
@@ -0,0 +1,663 @@
+package hls
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseError reports malformed playlist input; Line is 1-based and 0 for
+// problems with the playlist as a whole
+type ParseError struct {
+	Line int
+	Msg  string
+}
+
+// Error renders the error with its line number
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return "m3u8: " + e.Msg
+	}
+	return fmt.Sprintf("m3u8: line %d: %s", e.Line, e.Msg)
+}
+
+// parser holds the state of a single Parse call
+type parser struct {
+	base   *url.URL
+	line   int
+	master *MasterPlaylist
+	media  *MediaPlaylist
+
+	// Tags that apply to the next URI line
+	streamInf     *Variant
+	segment       *Segment
+	byteRange     *ByteRange
+	discontinuity bool
+	programDate   time.Time
+
+	// Tags that apply until replaced
+	key         *Key
+	initMap     *Map
+	version     int
+	hasTarget   bool
+	independent bool
+
+	// End of the previous byte range, per URI, for ranges without offset
+	rangeEnds map[string]int64
+}
+
+// Parse reads a master or media playlist; relative URIs are resolved
+// against playlistURL, which may be empty
+func Parse(r io.Reader, playlistURL string) (Playlist, error) {
+	p := &parser{rangeEnds: make(map[string]int64)}
+	if playlistURL != "" {
+		base, err := url.Parse(playlistURL)
+		if err != nil {
+			return nil, &ParseError{Msg: "invalid playlist URL: " + err.Error()}
+		}
+		p.base = base
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		p.line++
+		line := strings.TrimSpace(scanner.Text())
+		if p.line == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+			if line != "#EXTM3U" {
+				return nil, p.errorf("expected #EXTM3U, got %q", line)
+			}
+			continue
+		}
+		if err := p.parseLine(line); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &ParseError{Line: p.line + 1, Msg: err.Error()}
+	}
+	if p.line == 0 {
+		return nil, &ParseError{Msg: "empty playlist"}
+	}
+
+	return p.finish()
+}
+
+// ParseMaster parses a playlist that must be a master playlist
+func ParseMaster(r io.Reader, playlistURL string) (*MasterPlaylist, error) {
+	pl, err := Parse(r, playlistURL)
+	if err != nil {
+		return nil, err
+	}
+	master, ok := pl.(*MasterPlaylist)
+	if !ok {
+		return nil, &ParseError{Msg: "expected a master playlist, got a media playlist"}
+	}
+	return master, nil
+}
+
+// ParseMedia parses a playlist that must be a media playlist
+func ParseMedia(r io.Reader, playlistURL string) (*MediaPlaylist, error) {
+	pl, err := Parse(r, playlistURL)
+	if err != nil {
+		return nil, err
+	}
+	media, ok := pl.(*MediaPlaylist)
+	if !ok {
+		return nil, &ParseError{Msg: "expected a media playlist, got a master playlist"}
+	}
+	return media, nil
+}
+
+// errorf returns a ParseError for the current line
+func (p *parser) errorf(format string, args ...any) error {
+	return &ParseError{Line: p.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseLine handles one trimmed line after the #EXTM3U header
+func (p *parser) parseLine(line string) error {
+	switch {
+	case line == "":
+		return nil
+	case strings.HasPrefix(line, "#EXT"):
+		name, value, _ := strings.Cut(line[1:], ":")
+		return p.parseTag(name, value)
+	case strings.HasPrefix(line, "#"):
+		// Comment
+		return nil
+	default:
+		return p.parseURI(line)
+	}
+}
+
+// parseTag handles a single #EXT tag; unknown tags are ignored as the
+// specification requires
+func (p *parser) parseTag(name, value string) error {
+	switch name {
+	// Tags for both playlist kinds
+	case "EXT-X-VERSION":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return p.errorf("invalid EXT-X-VERSION %q", value)
+		}
+		p.version = n
+	case "EXT-X-INDEPENDENT-SEGMENTS":
+		p.independent = true
+
+	// Master playlist tags
+	case "EXT-X-STREAM-INF":
+		if err := p.useMaster(name); err != nil {
+			return err
+		}
+		v, err := p.parseVariant(value, false)
+		if err != nil {
+			return err
+		}
+		p.streamInf = v
+	case "EXT-X-I-FRAME-STREAM-INF":
+		if err := p.useMaster(name); err != nil {
+			return err
+		}
+		v, err := p.parseVariant(value, true)
+		if err != nil {
+			return err
+		}
+		p.master.IFrameVariants = append(p.master.IFrameVariants, *v)
+	case "EXT-X-MEDIA":
+		if err := p.useMaster(name); err != nil {
+			return err
+		}
+		r, err := p.parseRendition(value)
+		if err != nil {
+			return err
+		}
+		p.master.Renditions = append(p.master.Renditions, *r)
+
+	// Media playlist tags
+	case "EXT-X-TARGETDURATION":
+		if err := p.useMedia(name); err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return p.errorf("invalid EXT-X-TARGETDURATION %q", value)
+		}
+		p.media.TargetDuration = time.Duration(n) * time.Second
+		p.hasTarget = true
+	case "EXT-X-MEDIA-SEQUENCE":
+		if err := p.useMedia(name); err != nil {
+			return err
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return p.errorf("invalid EXT-X-MEDIA-SEQUENCE %q", value)
+		}
+		p.media.MediaSequence = n
+	case "EXT-X-DISCONTINUITY-SEQUENCE":
+		if err := p.useMedia(name); err != nil {
+			return err
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return p.errorf("invalid EXT-X-DISCONTINUITY-SEQUENCE %q", value)
+		}
+		p.media.DiscontinuitySequence = n
+	case "EXT-X-PLAYLIST-TYPE":
+		if err := p.useMedia(name); err != nil {
+			return err
+		}
+		if value != "VOD" && value != "EVENT" {
+			return p.errorf("EXT-X-PLAYLIST-TYPE must be VOD or EVENT, got %q", value)
+		}
+		p.media.PlaylistType = value
+	case "EXT-X-ENDLIST":
+		if err := p.useMedia(name); err != nil {
+			return err
+		}
+		p.media.EndList = true
+	case "EXT-X-I-FRAMES-ONLY":
+		if err := p.useMedia(name); err != nil {
+			return err
+		}
+		p.media.IFramesOnly = true
+	case "EXTINF":
+		if err := p.useMedia(name); err != nil {
+			return err
+		}
+		return p.parseExtinf(value)
+	case "EXT-X-BYTERANGE":
+		if err := p.useMedia(name); err != nil {
+			return err
+		}
+		br, err := parseByteRange(value)
+		if err != nil {
+			return p.errorf("invalid EXT-X-BYTERANGE %q: %s", value, err)
+		}
+		p.byteRange = br
+	case "EXT-X-DISCONTINUITY":
+		if err := p.useMedia(name); err != nil {
+			return err
+		}
+		p.discontinuity = true
+	case "EXT-X-PROGRAM-DATE-TIME":
+		if err := p.useMedia(name); err != nil {
+			return err
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			// Some servers write the offset without a colon
+			t, err = time.Parse("2006-01-02T15:04:05.999999999Z0700", value)
+		}
+		if err != nil {
+			return p.errorf("invalid EXT-X-PROGRAM-DATE-TIME %q", value)
+		}
+		p.programDate = t
+	case "EXT-X-KEY":
+		if err := p.useMedia(name); err != nil {
+			return err
+		}
+		key, err := p.parseKey(value)
+		if err != nil {
+			return err
+		}
+		p.key = key
+	case "EXT-X-MAP":
+		if err := p.useMedia(name); err != nil {
+			return err
+		}
+		m, err := p.parseMap(value)
+		if err != nil {
+			return err
+		}
+		p.initMap = m
+	}
+	return nil
+}
+
+// useMaster marks the playlist as a master playlist
+func (p *parser) useMaster(tag string) error {
+	if p.media != nil {
+		return p.errorf("master playlist tag #%s in a media playlist", tag)
+	}
+	if p.master == nil {
+		p.master = &MasterPlaylist{}
+	}
+	return nil
+}
+
+// useMedia marks the playlist as a media playlist
+func (p *parser) useMedia(tag string) error {
+	if p.master != nil {
+		return p.errorf("media playlist tag #%s in a master playlist", tag)
+	}
+	if p.media == nil {
+		p.media = &MediaPlaylist{}
+	}
+	return nil
+}
+
+// parseURI handles a URI line, which completes a variant or a segment
+func (p *parser) parseURI(line string) error {
+	uri, err := p.resolve(line)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case p.streamInf != nil:
+		p.streamInf.URI = uri
+		p.master.Variants = append(p.master.Variants, *p.streamInf)
+		p.streamInf = nil
+		return nil
+
+	case p.segment != nil:
+		seg := p.segment
+		seg.URI = uri
+		seg.Key = p.key
+		seg.Map = p.initMap
+		seg.Discontinuity = p.discontinuity
+		seg.ProgramDateTime = p.programDate
+
+		// A range without offset continues the previous range of the
+		// same resource
+		if br := p.byteRange; br != nil {
+			if br.Offset < 0 {
+				end, ok := p.rangeEnds[uri]
+				if !ok {
+					return p.errorf("EXT-X-BYTERANGE without offset does not follow a range of %s", line)
+				}
+				br.Offset = end
+			}
+			p.rangeEnds[uri] = br.End()
+			seg.ByteRange = br
+		}
+
+		p.media.Segments = append(p.media.Segments, *seg)
+		p.segment = nil
+		p.byteRange = nil
+		p.discontinuity = false
+		p.programDate = time.Time{}
+		return nil
+
+	case p.master != nil:
+		return p.errorf("URI %q without #EXT-X-STREAM-INF", line)
+	default:
+		return p.errorf("segment URI %q without #EXTINF", line)
+	}
+}
+
+// parseExtinf handles #EXTINF:<duration>,[<title>]
+func (p *parser) parseExtinf(value string) error {
+	if p.segment != nil {
+		return p.errorf("#EXTINF without a segment URI after the previous #EXTINF")
+	}
+
+	duration, title, _ := strings.Cut(value, ",")
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(duration), 64)
+	if err != nil || seconds < 0 {
+		return p.errorf("invalid #EXTINF duration %q", duration)
+	}
+	p.segment = &Segment{
+		Duration: time.Duration(seconds * float64(time.Second)),
+		Title:    strings.TrimSpace(title),
+	}
+	return nil
+}
+
+// parseVariant handles the attributes of EXT-X-STREAM-INF and
+// EXT-X-I-FRAME-STREAM-INF; the latter carries its URI as an attribute
+func (p *parser) parseVariant(value string, iframe bool) (*Variant, error) {
+	attrs, err := p.parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Variant{
+		Audio:          attrs["AUDIO"],
+		Video:          attrs["VIDEO"],
+		Subtitles:      attrs["SUBTITLES"],
+		ClosedCaptions: attrs["CLOSED-CAPTIONS"],
+	}
+
+	bandwidth, ok := attrs["BANDWIDTH"]
+	if !ok {
+		return nil, p.errorf("variant without BANDWIDTH")
+	}
+	if v.Bandwidth, err = strconv.ParseInt(bandwidth, 10, 64); err != nil || v.Bandwidth < 0 {
+		return nil, p.errorf("invalid BANDWIDTH %q", bandwidth)
+	}
+	if s, ok := attrs["AVERAGE-BANDWIDTH"]; ok {
+		if v.AverageBandwidth, err = strconv.ParseInt(s, 10, 64); err != nil || v.AverageBandwidth < 0 {
+			return nil, p.errorf("invalid AVERAGE-BANDWIDTH %q", s)
+		}
+	}
+	if s, ok := attrs["RESOLUTION"]; ok {
+		w, h, found := strings.Cut(strings.ToLower(s), "x")
+		v.Resolution.Width, err = strconv.Atoi(w)
+		if err == nil {
+			v.Resolution.Height, err = strconv.Atoi(h)
+		}
+		if !found || err != nil {
+			return nil, p.errorf("invalid RESOLUTION %q", s)
+		}
+	}
+	if s, ok := attrs["CODECS"]; ok {
+		for _, codec := range strings.Split(s, ",") {
+			if codec = strings.TrimSpace(codec); codec != "" {
+				v.Codecs = append(v.Codecs, codec)
+			}
+		}
+	}
+	if s, ok := attrs["FRAME-RATE"]; ok {
+		if v.FrameRate, err = strconv.ParseFloat(s, 64); err != nil || v.FrameRate < 0 {
+			return nil, p.errorf("invalid FRAME-RATE %q", s)
+		}
+	}
+
+	if iframe {
+		uri, ok := attrs["URI"]
+		if !ok {
+			return nil, p.errorf("EXT-X-I-FRAME-STREAM-INF without URI")
+		}
+		if v.URI, err = p.resolve(uri); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// parseRendition handles the attributes of EXT-X-MEDIA
+func (p *parser) parseRendition(value string) (*Rendition, error) {
+	attrs, err := p.parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Rendition{
+		Type:       attrs["TYPE"],
+		GroupID:    attrs["GROUP-ID"],
+		Name:       attrs["NAME"],
+		Language:   attrs["LANGUAGE"],
+		Default:    attrs["DEFAULT"] == "YES",
+		Autoselect: attrs["AUTOSELECT"] == "YES",
+		Forced:     attrs["FORCED"] == "YES",
+		Channels:   attrs["CHANNELS"],
+		InstreamID: attrs["INSTREAM-ID"],
+	}
+
+	switch r.Type {
+	case "AUDIO", "VIDEO", "SUBTITLES", "CLOSED-CAPTIONS":
+	case "":
+		return nil, p.errorf("EXT-X-MEDIA without TYPE")
+	default:
+		return nil, p.errorf("invalid EXT-X-MEDIA TYPE %q", r.Type)
+	}
+	if r.GroupID == "" {
+		return nil, p.errorf("EXT-X-MEDIA without GROUP-ID")
+	}
+	if r.Name == "" {
+		return nil, p.errorf("EXT-X-MEDIA without NAME")
+	}
+	if uri, ok := attrs["URI"]; ok {
+		if r.Type == "CLOSED-CAPTIONS" {
+			return nil, p.errorf("CLOSED-CAPTIONS rendition must not have a URI")
+		}
+		if r.URI, err = p.resolve(uri); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// parseKey handles the attributes of EXT-X-KEY; METHOD=NONE clears the key
+func (p *parser) parseKey(value string) (*Key, error) {
+	attrs, err := p.parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+
+	method, ok := attrs["METHOD"]
+	if !ok {
+		return nil, p.errorf("EXT-X-KEY without METHOD")
+	}
+	if method == "NONE" {
+		return nil, nil
+	}
+
+	key := &Key{
+		Method:            method,
+		KeyFormat:         attrs["KEYFORMAT"],
+		KeyFormatVersions: attrs["KEYFORMATVERSIONS"],
+	}
+	uri, ok := attrs["URI"]
+	if !ok {
+		return nil, p.errorf("EXT-X-KEY METHOD=%s without URI", method)
+	}
+	// Key URIs may be data: or skd: URIs, which resolve to themselves
+	if key.URI, err = p.resolve(uri); err != nil {
+		return nil, err
+	}
+	if iv, ok := attrs["IV"]; ok {
+		if key.IV, err = parseIV(iv); err != nil {
+			return nil, p.errorf("invalid IV %q: %s", iv, err)
+		}
+	}
+	return key, nil
+}
+
+// parseMap handles the attributes of EXT-X-MAP
+func (p *parser) parseMap(value string) (*Map, error) {
+	attrs, err := p.parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+
+	uri, ok := attrs["URI"]
+	if !ok {
+		return nil, p.errorf("EXT-X-MAP without URI")
+	}
+	m := &Map{Key: p.key}
+	if m.URI, err = p.resolve(uri); err != nil {
+		return nil, err
+	}
+	if s, ok := attrs["BYTERANGE"]; ok {
+		br, err := parseByteRange(s)
+		if err != nil {
+			return nil, p.errorf("invalid EXT-X-MAP BYTERANGE %q: %s", s, err)
+		}
+		// The offset defaults to the start of the resource here
+		br.Offset = max(br.Offset, 0)
+		m.ByteRange = br
+	}
+	return m, nil
+}
+
+// parseAttributes splits an attribute list such as
+// BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2" into unquoted values
+func (p *parser) parseAttributes(value string) (map[string]string, error) {
+	attrs := make(map[string]string)
+	for rest := strings.TrimSpace(value); rest != ""; {
+		name, after, ok := strings.Cut(rest, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, p.errorf("malformed attribute list near %q", rest)
+		}
+		after = strings.TrimLeft(after, " ")
+
+		var val string
+		if strings.HasPrefix(after, `"`) {
+			end := strings.IndexByte(after[1:], '"')
+			if end < 0 {
+				return nil, p.errorf("unterminated quoted string in attribute %s", name)
+			}
+			val = after[1 : end+1]
+			after = strings.TrimLeft(after[end+2:], " ")
+			if after != "" && after[0] != ',' {
+				return nil, p.errorf("expected ',' after attribute %s", name)
+			}
+		} else {
+			val, after, _ = strings.Cut(after, ",")
+			val = strings.TrimSpace(val)
+			after = "," + after
+		}
+
+		if _, dup := attrs[name]; dup {
+			return nil, p.errorf("duplicate attribute %s", name)
+		}
+		attrs[name] = val
+		rest = strings.TrimSpace(strings.TrimPrefix(after, ","))
+	}
+	return attrs, nil
+}
+
+// finish checks the playlist as a whole once every line is read
+func (p *parser) finish() (Playlist, error) {
+	switch {
+	case p.master != nil:
+		if p.streamInf != nil {
+			return nil, &ParseError{Line: p.line, Msg: "#EXT-X-STREAM-INF without a URI line"}
+		}
+		if len(p.master.Variants) == 0 && len(p.master.IFrameVariants) == 0 {
+			return nil, &ParseError{Msg: "master playlist has no variants"}
+		}
+		p.master.Version = p.version
+		p.master.IndependentSegments = p.independent
+		if p.base != nil {
+			p.master.URL = p.base.String()
+		}
+		return p.master, nil
+
+	case p.media != nil:
+		if p.segment != nil {
+			return nil, &ParseError{Line: p.line, Msg: "#EXTINF without a segment URI"}
+		}
+		if !p.hasTarget {
+			return nil, &ParseError{Msg: "media playlist has no #EXT-X-TARGETDURATION"}
+		}
+		p.media.Version = p.version
+		p.media.IndependentSegments = p.independent
+		if p.base != nil {
+			p.media.URL = p.base.String()
+		}
+		// MEDIA-SEQUENCE may appear after the first segment tags
+		for i := range p.media.Segments {
+			p.media.Segments[i].Sequence = p.media.MediaSequence + uint64(i)
+		}
+		return p.media, nil
+
+	default:
+		return nil, &ParseError{Msg: "playlist has neither variants nor segments"}
+	}
+}
+
+// resolve turns a URI from the playlist into an absolute URL when the
+// playlist URL is known
+func (p *parser) resolve(uri string) (string, error) {
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return "", p.errorf("invalid URI %q", uri)
+	}
+	if p.base == nil {
+		return uri, nil
+	}
+	return p.base.ResolveReference(ref).String(), nil
+}
+
+// parseByteRange parses <length>[@<offset>]; a missing offset is -1
+func parseByteRange(value string) (*ByteRange, error) {
+	length, offset, hasOffset := strings.Cut(strings.TrimSpace(value), "@")
+	br := &ByteRange{Offset: -1}
+
+	var err error
+	if br.Length, err = strconv.ParseInt(length, 10, 64); err != nil || br.Length < 0 {
+		return nil, fmt.Errorf("length must be a whole number")
+	}
+	if hasOffset {
+		if br.Offset, err = strconv.ParseInt(offset, 10, 64); err != nil || br.Offset < 0 {
+			return nil, fmt.Errorf("offset must be a whole number")
+		}
+	}
+	return br, nil
+}
+
+// parseIV decodes a 0x-prefixed 128-bit hexadecimal IV
+func parseIV(value string) ([]byte, error) {
+	digits, ok := strings.CutPrefix(strings.ToLower(value), "0x")
+	if !ok {
+		return nil, fmt.Errorf("IV must start with 0x")
+	}
+	if len(digits) > 32 {
+		return nil, fmt.Errorf("IV is longer than 128 bits")
+	}
+	iv, err := hex.DecodeString(strings.Repeat("0", 32-len(digits)) + digits)
+	if err != nil {
+		return nil, fmt.Errorf("IV is not hexadecimal")
+	}
+	return iv, nil
+}
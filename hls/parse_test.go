@@ -0,0 +1,232 @@
+package hls
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDetectsPlaylistKind(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		master bool
+	}{
+		{
+			name: "master",
+			input: `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=1280000
+low.m3u8
+`,
+			master: true,
+		},
+		{
+			name: "media",
+			input: `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXTINF:9.5,
+seg0.ts
+#EXT-X-ENDLIST
+`,
+		},
+		{
+			name:  "media with a byte order mark",
+			input: "\ufeff#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,\nseg0.ts\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl, err := Parse(strings.NewReader(tt.input), "")
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if _, ok := pl.(*MasterPlaylist); ok != tt.master {
+				t.Errorf("Parse returned %T, master = %v", pl, tt.master)
+			}
+		})
+	}
+}
+
+func TestParseErrorLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  int
+	}{
+		{
+			name:  "missing header",
+			input: "#EXT-X-TARGETDURATION:10\n",
+			line:  1,
+		},
+		{
+			name:  "bad duration",
+			input: "#EXTM3U\n#EXT-X-TARGETDURATION:10\n\n#EXTINF:abc,\nseg0.ts\n",
+			line:  4,
+		},
+		{
+			name:  "unterminated quote",
+			input: "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1,CODECS=\"avc1\nlow.m3u8\n",
+			line:  2,
+		},
+		{
+			name:  "URI without EXTINF",
+			input: "#EXTM3U\n#EXT-X-TARGETDURATION:10\nseg0.ts\n",
+			line:  3,
+		},
+		{
+			name:  "no target duration",
+			input: "#EXTM3U\n#EXTINF:10,\nseg0.ts\n",
+			line:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input), "")
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("Parse error = %v, want a *ParseError", err)
+			}
+			if perr.Line != tt.line {
+				t.Errorf("Parse error line = %d, want %d (%v)", perr.Line, tt.line, err)
+			}
+		})
+	}
+}
+
+func TestParseResolvesRelativeURIs(t *testing.T) {
+	input := `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXT-X-KEY:METHOD=AES-128,URI="../keys/k1"
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:10,
+seg0.m4s
+#EXTINF:10,
+/abs/seg1.m4s
+#EXTINF:10,
+https://cdn.example.com/seg2.m4s
+#EXT-X-ENDLIST
+`
+	pl, err := ParseMedia(strings.NewReader(input), "https://example.com/video/hd/index.m3u8?token=1")
+	if err != nil {
+		t.Fatalf("ParseMedia: %v", err)
+	}
+
+	want := []string{
+		"https://example.com/video/hd/seg0.m4s",
+		"https://example.com/abs/seg1.m4s",
+		"https://cdn.example.com/seg2.m4s",
+	}
+	for i, seg := range pl.Segments {
+		if seg.URI != want[i] {
+			t.Errorf("segment %d URI = %q, want %q", i, seg.URI, want[i])
+		}
+	}
+	if got := pl.Segments[0].Key.URI; got != "https://example.com/video/keys/k1" {
+		t.Errorf("key URI = %q", got)
+	}
+	if got := pl.Segments[0].Map.URI; got != "https://example.com/video/hd/init.mp4" {
+		t.Errorf("map URI = %q", got)
+	}
+}
+
+func TestParseByteRangeContinuesPreviousRange(t *testing.T) {
+	input := `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXTINF:10,
+#EXT-X-BYTERANGE:1000@200
+all.ts
+#EXTINF:10,
+#EXT-X-BYTERANGE:500
+other.ts
+#EXTINF:10,
+#EXT-X-BYTERANGE:800
+all.ts
+#EXTINF:10,
+#EXT-X-BYTERANGE:300@0
+other.ts
+#EXTINF:10,
+#EXT-X-BYTERANGE:400
+other.ts
+`
+	// other.ts has no previous range for the second segment
+	if _, err := Parse(strings.NewReader(input), ""); err == nil {
+		t.Fatal("Parse accepted a range without offset and without a previous range")
+	}
+
+	input = strings.Replace(input, "#EXT-X-BYTERANGE:500\n", "#EXT-X-BYTERANGE:500@0\n", 1)
+	pl, err := ParseMedia(strings.NewReader(input), "")
+	if err != nil {
+		t.Fatalf("ParseMedia: %v", err)
+	}
+
+	want := []ByteRange{
+		{Length: 1000, Offset: 200},
+		{Length: 500, Offset: 0},
+		{Length: 800, Offset: 1200}, // after the range of all.ts, not other.ts
+		{Length: 300, Offset: 0},
+		{Length: 400, Offset: 300},
+	}
+	for i, seg := range pl.Segments {
+		if seg.ByteRange == nil || *seg.ByteRange != want[i] {
+			t.Errorf("segment %d range = %+v, want %+v", i, seg.ByteRange, want[i])
+		}
+	}
+}
+
+func TestParseQuotedAttributesWithCommas(t *testing.T) {
+	input := `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English, Stereo",LANGUAGE="en",URI="audio/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2",RESOLUTION=1280x720,AUDIO="aac"
+hd.m3u8
+`
+	pl, err := ParseMaster(strings.NewReader(input), "https://example.com/master.m3u8")
+	if err != nil {
+		t.Fatalf("ParseMaster: %v", err)
+	}
+
+	v := pl.Variants[0]
+	if want := []string{"avc1.4d401f", "mp4a.40.2"}; !reflect.DeepEqual(v.Codecs, want) {
+		t.Errorf("codecs = %q, want %q", v.Codecs, want)
+	}
+	if v.Bandwidth != 1280000 || v.Resolution != (Resolution{1280, 720}) || v.Audio != "aac" {
+		t.Errorf("variant = %+v", v)
+	}
+
+	r := pl.Renditions[0]
+	if r.Name != "English, Stereo" || r.Language != "en" || r.URI != "https://example.com/audio/en.m3u8" {
+		t.Errorf("rendition = %+v", r)
+	}
+}
+
+func TestParseSegments(t *testing.T) {
+	input := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXTINF:5.005,first
+seg10.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:4,
+seg11.ts
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-ENDLIST
+`
+	pl, err := ParseMedia(strings.NewReader(input), "")
+	if err != nil {
+		t.Fatalf("ParseMedia: %v", err)
+	}
+	if pl.TargetDuration != 6*time.Second || !pl.EndList || pl.Live() {
+		t.Errorf("playlist = %+v", pl)
+	}
+
+	want := []Segment{
+		{URI: "seg10.ts", Sequence: 10, Duration: 5005 * time.Millisecond, Title: "first"},
+		{URI: "seg11.ts", Sequence: 11, Duration: 4 * time.Second, Discontinuity: true},
+	}
+	if !reflect.DeepEqual(pl.Segments, want) {
+		t.Errorf("segments\n got %+v\nwant %+v", pl.Segments, want)
+	}
+}
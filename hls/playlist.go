@@ -0,0 +1,172 @@
+// Package hls parses HLS (M3U8) master and media playlists.
+package hls
+
+import (
+	"fmt"
+	"time"
+)
+
+// Playlist is either a *MasterPlaylist or a *MediaPlaylist
+type Playlist interface {
+	playlist()
+}
+
+// MasterPlaylist lists the variant streams and renditions of a
+// presentation
+type MasterPlaylist struct {
+	URL                 string
+	Version             int
+	IndependentSegments bool
+	Variants            []Variant
+	IFrameVariants      []Variant
+	Renditions          []Rendition
+}
+
+// Variant is one EXT-X-STREAM-INF (or EXT-X-I-FRAME-STREAM-INF) entry
+type Variant struct {
+	URI              string
+	Bandwidth        int64
+	AverageBandwidth int64
+	Resolution       Resolution
+	Codecs           []string
+	FrameRate        float64
+
+	// Rendition group IDs
+	Audio          string
+	Video          string
+	Subtitles      string
+	ClosedCaptions string
+}
+
+// Resolution is a video size in pixels; zero when not given
+type Resolution struct {
+	Width  int
+	Height int
+}
+
+// String renders the resolution as WIDTHxHEIGHT
+func (r Resolution) String() string {
+	return fmt.Sprintf("%dx%d", r.Width, r.Height)
+}
+
+// Best returns the variant with the highest bandwidth, or nil when the
+// playlist only has I-frame variants
+func (p *MasterPlaylist) Best() *Variant {
+	var best *Variant
+	for i := range p.Variants {
+		if best == nil || p.Variants[i].Bandwidth > best.Bandwidth {
+			best = &p.Variants[i]
+		}
+	}
+	return best
+}
+
+// SeparateAudio returns an audio rendition of v's group that is a
+// playlist of its own rather than muxed into v, or nil when there is none
+func (p *MasterPlaylist) SeparateAudio(v *Variant) *Rendition {
+	if v.Audio == "" {
+		return nil
+	}
+	for i, r := range p.Renditions {
+		if r.Type == "AUDIO" && r.GroupID == v.Audio && r.URI != "" {
+			return &p.Renditions[i]
+		}
+	}
+	return nil
+}
+
+// Rendition is an EXT-X-MEDIA alternative audio, video, subtitle or
+// closed caption track
+type Rendition struct {
+	Type       string // AUDIO, VIDEO, SUBTITLES or CLOSED-CAPTIONS
+	GroupID    string
+	Name       string
+	Language   string
+	URI        string // empty when the rendition is muxed into the variant
+	Default    bool
+	Autoselect bool
+	Forced     bool
+	Channels   string
+	InstreamID string
+}
+
+// MediaPlaylist lists the segments of one stream
+type MediaPlaylist struct {
+	URL                   string
+	Version               int
+	TargetDuration        time.Duration
+	MediaSequence         uint64
+	DiscontinuitySequence uint64
+	PlaylistType          string // VOD, EVENT or empty
+	EndList               bool
+	IFramesOnly           bool
+	IndependentSegments   bool
+	Segments              []Segment
+}
+
+// Segment is one media segment; Key and Map are shared with the
+// neighbouring segments they apply to
+type Segment struct {
+	URI             string
+	Sequence        uint64
+	Duration        time.Duration
+	Title           string
+	ByteRange       *ByteRange
+	Key             *Key
+	Map             *Map
+	Discontinuity   bool
+	ProgramDateTime time.Time // zero when not given
+}
+
+// ByteRange is a sub-range of a resource
+type ByteRange struct {
+	Length int64
+	Offset int64
+}
+
+// End returns the offset just past the range
+func (b ByteRange) End() int64 {
+	return b.Offset + b.Length
+}
+
+// Key is an EXT-X-KEY; segments without encryption have a nil Key
+type Key struct {
+	Method            string // AES-128, SAMPLE-AES, ...
+	URI               string
+	IV                []byte // nil when derived from the media sequence
+	KeyFormat         string
+	KeyFormatVersions string
+}
+
+// Map is an EXT-X-MAP media initialization section
+type Map struct {
+	URI       string
+	ByteRange *ByteRange
+	Key       *Key // the EXT-X-KEY in effect at the tag; nil when unencrypted
+}
+
+// Fragmented reports whether the segments are fragmented MP4 (CMAF),
+// which always come with an init section
+func (p *MediaPlaylist) Fragmented() bool {
+	return len(p.Segments) > 0 && p.Segments[0].Map != nil
+}
+
+// Live reports whether the playlist may still grow
+func (p *MediaPlaylist) Live() bool {
+	return !p.EndList && p.PlaylistType != "VOD"
+}
+
+// Duration returns the total duration of the segments
+func (p *MediaPlaylist) Duration() time.Duration {
+	var total time.Duration
+	for _, s := range p.Segments {
+		total += s.Duration
+	}
+	return total
+}
+
+// playlist marks MasterPlaylist as a Playlist
+func (*MasterPlaylist) playlist() {}
+
+// playlist marks MediaPlaylist as a Playlist
+func (*MediaPlaylist) playlist() {}
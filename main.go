@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,6 +10,19 @@ import (
 )
 
 func main() {
+	// Background supervisor for a detached download
+	if len(os.Args) == 3 && os.Args[1] == "supervise" {
+		os.Exit(RunSupervisor(os.Args[2]))
+	}
+
+	// Command line options
+	var batchFile string
+	flag.StringVar(&batchFile, "a", "", "queue the URLs listed in `FILE` (yt-dlp batch-file syntax)")
+	flag.StringVar(&batchFile, "batch-file", "", "same as -a")
+	var summaryFile string
+	flag.StringVar(&summaryFile, "summary-json", "", "save the session summary as JSON to `FILE` on exit")
+	flag.Parse()
+
 	// Check if yt-dlp is available
 	if !CheckYtDlpAvailable() {
 		fmt.Println("Error: yt-dlp is not installed or not in PATH")
@@ -21,12 +35,41 @@ func main() {
 	// Ensure default download folder exists
 	ensureDefaultDownloadFolder()
 
+	model := InitialModel()
+
+	// Queue a batch file given on the command line; rejected lines are
+	// reported in the TUI
+	if batchFile != "" {
+		result, err := ReadBatchFile(batchFile, model.form, model.features)
+		if err != nil {
+			fmt.Printf("Error: cannot read batch file: %v\n", err)
+			os.Exit(1)
+		}
+		model.importBatch(result)
+		model.showQueue = len(result.Items) > 0
+	}
+
 	// Create and run the Bubble Tea program
-	p := tea.NewProgram(InitialModel())
-	if _, err := p.Run(); err != nil {
+	p := tea.NewProgram(model)
+	final, err := p.Run()
+	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
+
+	// Report what this session downloaded
+	if m, ok := final.(Model); ok {
+		summary := m.Summary()
+		if summary.Finished() {
+			fmt.Print(summary.String())
+		}
+		if summaryFile != "" {
+			if err := summary.WriteJSON(summaryFile); err != nil {
+				fmt.Printf("Error: cannot save summary: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
 }
 
 // ensureDefaultDownloadFolder creates "yt-dlp Downloads" folder if it doesn't exist
@@ -5,6 +5,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -15,34 +16,133 @@ type Field int
 const (
 	FieldURL Field = iota
 	FieldConcurrent
+	FieldParallelJobs
+	FieldStallTimeout
 	FieldOutputFolder
 	FieldSubtitles
 	FieldPlaylist
+	FieldPlaylistStart
+	FieldPlaylistEnd
+	FieldPlaylistReverse
+	FieldMaxDownloads
+	FieldDateAfter
+	FieldDateBefore
+	FieldTitleMatch
+	FieldTitleReject
+	FieldMinDuration
+	FieldMaxDuration
+	FieldDetach
+	FieldRateLimit
+	FieldRetries
+	FieldFragmentRetries
+	FieldSocketTimeout
+	FieldLowPriority
+	FieldArchive
+	FieldEngine
+	FieldLive
+	FieldRecordFor
 	FieldExtraFlags
 	FieldDownloadButton
+
+	// FieldImportPath is the path prompt of the batch import dialog,
+	// outside the form's navigation order
+	FieldImportPath
+
+	// FieldPickerRange is the range prompt of the playlist picker
+	FieldPickerRange
 )
 
+// DownloadOptions holds the form values that shape a yt-dlp command;
+// queue items keep a snapshot of them
+type DownloadOptions struct {
+	URL          string `json:"url"`
+	Concurrent   string `json:"concurrent"`
+	StallTimeout string `json:"stall_timeout"`
+	OutputFolder string `json:"output_folder"`
+	Subtitles    bool   `json:"subtitles"`
+	Playlist     bool   `json:"playlist"`
+	Detach       bool   `json:"detach"`
+	ExtraFlags   string `json:"extra_flags"`
+
+	// Network and resource controls
+	RateLimit       string `json:"rate_limit"`
+	Retries         string `json:"retries"`
+	FragmentRetries string `json:"fragment_retries"`
+	SocketTimeout   string `json:"socket_timeout"`
+	LowPriority     bool   `json:"low_priority"`
+
+	// Download archive mode: "", "folder" or "global"
+	Archive string `json:"archive,omitempty"`
+
+	// Download engine: "" for yt-dlp or "native" for the built-in HLS engine
+	Engine string `json:"engine,omitempty"`
+
+	// Live recording with the native engine: "", "edge" or "start", and
+	// an optional [h:]m:s limit
+	Live      string `json:"live,omitempty"`
+	RecordFor string `json:"record_for,omitempty"`
+
+	// Playlist entries picked in the playlist picker, e.g. "1-3,7"
+	PlaylistItems string `json:"playlist_items,omitempty"`
+
+	// Playlist range and filters, used only in Playlist Mode
+	PlaylistStart   string `json:"playlist_start,omitempty"`
+	PlaylistEnd     string `json:"playlist_end,omitempty"`
+	PlaylistReverse bool   `json:"playlist_reverse,omitempty"`
+	MaxDownloads    string `json:"max_downloads,omitempty"`
+	DateAfter       string `json:"date_after,omitempty"`
+	DateBefore      string `json:"date_before,omitempty"`
+	TitleMatch      string `json:"title_match,omitempty"`
+	TitleReject     string `json:"title_reject,omitempty"`
+	MinDuration     string `json:"min_duration,omitempty"`
+	MaxDuration     string `json:"max_duration,omitempty"`
+}
+
 // Model represents the application state
 type Model struct {
 	// Form fields
-	url          string
-	concurrent   string
-	outputFolder string
-	subtitles    bool
-	playlist     bool
-	extraFlags   string
+	form DownloadOptions
+
+	// Capabilities of the installed yt-dlp
+	features YtDlpFeatures
 
 	// UI state
 	focusedField Field
 	cursorPos    map[Field]int
 	err          string
 
+	// Queue state; parallelJobs is global and not part of the snapshots
+	parallelJobs string
+	queue        []*QueueItem
+	nextQueueID  int
+	queueCursor  int
+	marked       map[int]bool
+	probing      map[int]bool
+	showQueue    bool
+	resumePrompt bool
+	savedQueue   string
+	firstNewID   int
+
+	// Session summary; startedAt separates this run from restored results
+	startedAt   time.Time
+	showSummary bool
+	summaryNote string
+
+	// Batch import dialog
+	importing    bool
+	importPath   string
+	importReport []string
+
+	// Playlist entry picker; nil when closed
+	picker    *PlaylistPicker
+	pickerSeq int
+
 	// Download state
-	downloading     bool
-	downloadCmd     string
-	downloadOutput  []string
-	downloadSuccess *bool
-	spinnerFrame    int
+	sessions      map[int]*DownloadSession
+	nextSessionID int
+	spinnerFrame  int
+	ticking       bool
+	showRawLog    bool
 }
 
 // Spinner frames for download animation
@@ -51,22 +151,34 @@ var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "
 // TickMsg is sent periodically during download for spinner animation
 type TickMsg struct{}
 
-// DownloadCompleteMsg is sent when download finishes
+// DownloadCompleteMsg is sent when a download session finishes
 type DownloadCompleteMsg struct {
-	Success  bool
-	ExitCode int
+	SessionID int
+	Success   bool
+	ExitCode  int
+	Attempts  []Attempt
+	Failure   *Failure
 }
 
-// DownloadCompleteWithOutputMsg is sent when download finishes with all output
-type DownloadCompleteWithOutputMsg struct {
-	Success  bool
-	ExitCode int
-	Output   []string
+// DownloadOutputMsg contains streaming output from a download session
+type DownloadOutputMsg struct {
+	SessionID int
+	Line      string
 }
 
-// DownloadOutputMsg contains streaming output from yt-dlp
-type DownloadOutputMsg struct {
-	Line string
+// RenameCompleteMsg is sent after downloaded files were renamed
+type RenameCompleteMsg struct {
+	SessionID int
+	Note      string // why nothing was renamed, if so
+}
+
+// StartQueueMsg starts pending queue items once the program is running
+type StartQueueMsg struct{}
+
+// PartialCleanupMsg is sent after leftovers of a cancelled download are removed
+type PartialCleanupMsg struct {
+	SessionID int
+	Removed   int
 }
 
 // InitialModel creates the initial application state
@@ -74,46 +186,117 @@ func InitialModel() Model {
 	cursorPos := make(map[Field]int)
 	cursorPos[FieldURL] = 0
 	cursorPos[FieldConcurrent] = 0
+	cursorPos[FieldParallelJobs] = 0
+	cursorPos[FieldStallTimeout] = 0
 	cursorPos[FieldOutputFolder] = 0
 	cursorPos[FieldExtraFlags] = 0
+	cursorPos[FieldRateLimit] = 0
+	cursorPos[FieldRetries] = 0
+	cursorPos[FieldFragmentRetries] = 0
+	cursorPos[FieldSocketTimeout] = 0
 
 	// Get default download folder
 	defaultFolder := getDefaultDownloadFolder()
 
-	return Model{
-		url:             "",
-		concurrent:      "4",
-		outputFolder:    defaultFolder,
-		subtitles:       false,
-		playlist:        false,
-		extraFlags:      "",
-		focusedField:    FieldURL,
-		cursorPos:       cursorPos,
-		err:             "",
-		downloading:     false,
-		downloadCmd:     "",
-		downloadOutput:  []string{},
-		downloadSuccess: nil,
-		spinnerFrame:    0,
+	m := Model{
+		form: DownloadOptions{
+			Concurrent:   "4",
+			StallTimeout: "120",
+			OutputFolder: defaultFolder,
+		},
+		parallelJobs:  "1",
+		features:      DetectYtDlpFeatures(),
+		focusedField:  FieldURL,
+		cursorPos:     cursorPos,
+		err:           "",
+		sessions:      make(map[int]*DownloadSession),
+		marked:        make(map[int]bool),
+		probing:       make(map[int]bool),
+		nextQueueID:   1,
+		nextSessionID: 1,
+		spinnerFrame:  0,
+		startedAt:     time.Now(),
 	}
+
+	// Restore the queue of the previous run and reattach to downloads
+	// that kept running in the background
+	detached := LoadDetachedSessions(1)
+	m.nextSessionID = len(detached) + 1
+	m.resumePrompt = m.restoreQueue(LoadQueueState(), detached)
+	m.firstNewID = m.nextQueueID
+
+	return m
 }
 
 // Init implements tea.Model
 func (m Model) Init() tea.Cmd {
-	return nil
+	// Resume listening to reattached background downloads
+	var cmds []tea.Cmd
+	for _, s := range m.sessions {
+		cmds = append(cmds, s.Wait())
+	}
+	// Items queued before the program started, e.g. by --batch-file
+	if !m.resumePrompt && m.countItems(QueuePending) > 0 {
+		cmds = append(cmds, func() tea.Msg { return StartQueueMsg{} })
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	// Init runs on a copy of the model, so the tick chain started here is
+	// not recorded in m.ticking; a second chain only speeds up the spinner
+	return tea.Batch(append(cmds, tickSpinner())...)
 }
 
 // GetFieldValue returns the current value of a text field
 func (m Model) GetFieldValue(field Field) string {
 	switch field {
 	case FieldURL:
-		return m.url
+		return m.form.URL
 	case FieldConcurrent:
-		return m.concurrent
+		return m.form.Concurrent
+	case FieldParallelJobs:
+		return m.parallelJobs
+	case FieldStallTimeout:
+		return m.form.StallTimeout
 	case FieldOutputFolder:
-		return m.outputFolder
+		return m.form.OutputFolder
 	case FieldExtraFlags:
-		return m.extraFlags
+		return m.form.ExtraFlags
+	case FieldRateLimit:
+		return m.form.RateLimit
+	case FieldRetries:
+		return m.form.Retries
+	case FieldFragmentRetries:
+		return m.form.FragmentRetries
+	case FieldSocketTimeout:
+		return m.form.SocketTimeout
+	case FieldPlaylistStart:
+		return m.form.PlaylistStart
+	case FieldPlaylistEnd:
+		return m.form.PlaylistEnd
+	case FieldMaxDownloads:
+		return m.form.MaxDownloads
+	case FieldDateAfter:
+		return m.form.DateAfter
+	case FieldDateBefore:
+		return m.form.DateBefore
+	case FieldTitleMatch:
+		return m.form.TitleMatch
+	case FieldTitleReject:
+		return m.form.TitleReject
+	case FieldMinDuration:
+		return m.form.MinDuration
+	case FieldMaxDuration:
+		return m.form.MaxDuration
+	case FieldRecordFor:
+		return m.form.RecordFor
+	case FieldImportPath:
+		return m.importPath
+	case FieldPickerRange:
+		if m.picker != nil {
+			return m.picker.RangeSpec
+		}
+		return ""
 	default:
 		return ""
 	}
@@ -123,13 +306,51 @@ func (m Model) GetFieldValue(field Field) string {
 func (m *Model) SetFieldValue(field Field, value string) {
 	switch field {
 	case FieldURL:
-		m.url = value
+		m.form.URL = value
 	case FieldConcurrent:
-		m.concurrent = value
+		m.form.Concurrent = value
+	case FieldParallelJobs:
+		m.parallelJobs = value
+	case FieldStallTimeout:
+		m.form.StallTimeout = value
 	case FieldOutputFolder:
-		m.outputFolder = value
+		m.form.OutputFolder = value
 	case FieldExtraFlags:
-		m.extraFlags = value
+		m.form.ExtraFlags = value
+	case FieldRateLimit:
+		m.form.RateLimit = value
+	case FieldRetries:
+		m.form.Retries = value
+	case FieldFragmentRetries:
+		m.form.FragmentRetries = value
+	case FieldSocketTimeout:
+		m.form.SocketTimeout = value
+	case FieldPlaylistStart:
+		m.form.PlaylistStart = value
+	case FieldPlaylistEnd:
+		m.form.PlaylistEnd = value
+	case FieldMaxDownloads:
+		m.form.MaxDownloads = value
+	case FieldDateAfter:
+		m.form.DateAfter = value
+	case FieldDateBefore:
+		m.form.DateBefore = value
+	case FieldTitleMatch:
+		m.form.TitleMatch = value
+	case FieldTitleReject:
+		m.form.TitleReject = value
+	case FieldMinDuration:
+		m.form.MinDuration = value
+	case FieldMaxDuration:
+		m.form.MaxDuration = value
+	case FieldRecordFor:
+		m.form.RecordFor = value
+	case FieldImportPath:
+		m.importPath = value
+	case FieldPickerRange:
+		if m.picker != nil {
+			m.picker.RangeSpec = value
+		}
 	}
 }
 
@@ -251,32 +472,128 @@ func (m *Model) PasteFromClipboard() {
 		return
 	}
 
+	m.InsertText(clipboardText)
+}
+
+// InsertText inserts pasted text at cursor position; line breaks and tabs
+// become spaces so a pasted list of URLs stays on the single-line field
+func (m *Model) InsertText(text string) {
+	field := m.focusedField
+	if !m.isTextField(field) {
+		return
+	}
+
+	text = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, text)
+
 	value := m.GetFieldValue(field)
 	pos := m.GetCursorPos(field)
 
-	// Insert clipboard content at cursor position
-	newValue := value[:pos] + clipboardText + value[pos:]
+	// Insert text at cursor position
+	newValue := value[:pos] + text + value[pos:]
 	m.SetFieldValue(field, newValue)
-	m.SetCursorPos(field, pos+len(clipboardText))
+	m.SetCursorPos(field, pos+len(text))
 }
 
 // isTextField checks if field is a text input field
 func (m Model) isTextField(field Field) bool {
-	return field == FieldURL || field == FieldConcurrent ||
-		field == FieldOutputFolder || field == FieldExtraFlags
+	return field == FieldURL || field == FieldConcurrent || field == FieldParallelJobs ||
+		field == FieldStallTimeout ||
+		field == FieldOutputFolder || field == FieldExtraFlags ||
+		field == FieldRateLimit || field == FieldRetries ||
+		field == FieldFragmentRetries || field == FieldSocketTimeout ||
+		field == FieldPlaylistStart || field == FieldPlaylistEnd ||
+		field == FieldMaxDownloads || field == FieldDateAfter || field == FieldDateBefore ||
+		field == FieldTitleMatch || field == FieldTitleReject ||
+		field == FieldMinDuration || field == FieldMaxDuration || field == FieldRecordFor ||
+		field == FieldImportPath || field == FieldPickerRange
+}
+
+// isNumericField checks if field only accepts digits
+func (m Model) isNumericField(field Field) bool {
+	return field == FieldConcurrent || field == FieldParallelJobs || field == FieldStallTimeout ||
+		field == FieldPlaylistStart || field == FieldPlaylistEnd || field == FieldMaxDownloads
 }
 
-// AddOutputLine adds a line to download output
-func (m *Model) AddOutputLine(line string) {
-	m.downloadOutput = append(m.downloadOutput, strings.TrimSpace(line))
+// formFields returns the form fields in navigation order; the playlist
+// range and filter fields only exist in Playlist Mode, the live
+// recording fields only with the native engine
+func (m Model) formFields() []Field {
+	fields := []Field{
+		FieldURL,
+		FieldConcurrent,
+		FieldParallelJobs,
+		FieldStallTimeout,
+		FieldOutputFolder,
+		FieldSubtitles,
+		FieldPlaylist,
+	}
+	if m.form.Playlist {
+		fields = append(fields,
+			FieldPlaylistStart,
+			FieldPlaylistEnd,
+			FieldPlaylistReverse,
+			FieldMaxDownloads,
+			FieldDateAfter,
+			FieldDateBefore,
+			FieldTitleMatch,
+			FieldTitleReject,
+			FieldMinDuration,
+			FieldMaxDuration,
+		)
+	}
+	fields = append(fields,
+		FieldDetach,
+		FieldRateLimit,
+		FieldRetries,
+		FieldFragmentRetries,
+		FieldSocketTimeout,
+		FieldLowPriority,
+		FieldArchive,
+		FieldEngine,
+	)
+	if m.form.Engine == EngineNative {
+		fields = append(fields, FieldLive)
+		if m.form.Live != LiveOff {
+			fields = append(fields, FieldRecordFor)
+		}
+	}
+	return append(fields,
+		FieldExtraFlags,
+		FieldDownloadButton,
+	)
+}
+
+// fieldIndex returns the position of field in navigation order
+func (m Model) fieldIndex(field Field) int {
+	for i, f := range m.formFields() {
+		if f == field {
+			return i
+		}
+	}
+	return 0
+}
+
+// ActiveSession returns the download session of the selected queue item
+func (m Model) ActiveSession() *DownloadSession {
+	if item := m.SelectedItem(); item != nil {
+		return m.sessions[item.SessionID]
+	}
+	return nil
 }
 
-// GetLastOutputLines returns last n lines of output
-func (m Model) GetLastOutputLines(n int) []string {
-	if len(m.downloadOutput) <= n {
-		return m.downloadOutput
+// HasRunningSessions reports whether any download session is still running
+func (m Model) HasRunningSessions() bool {
+	for _, s := range m.sessions {
+		if s.Running() {
+			return true
+		}
 	}
-	return m.downloadOutput[len(m.downloadOutput)-n:]
+	return false
 }
 
 // getClipboardContent retrieves clipboard content (Linux)
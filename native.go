@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"hlsdownloader/hls"
+)
+
+// Download engines
+const (
+	EngineYtDlp  = ""
+	EngineNative = "native"
+)
+
+// Live recording modes of the native engine
+const (
+	LiveOff   = ""
+	LiveEdge  = "edge"
+	LiveStart = "start"
+)
+
+// Defaults of the native engine, matching yt-dlp where it has one
+const (
+	nativeRetries         = 10
+	nativeFragmentRetries = 10
+	nativeRetryDelay      = time.Second
+	nativeInfiniteRetries = 1 << 20
+)
+
+// NativeJob is a download run by the built-in HLS engine instead of yt-dlp
+type NativeJob struct {
+	URL     string
+	Workers int
+	Timeout time.Duration
+
+	// Retries applies to each segment, PlaylistRetries to each playlist
+	// request including live polls
+	Retries         int
+	PlaylistRetries int
+
+	// Live records a live stream from the live edge or the DVR start,
+	// for at most RecordFor when it is set
+	Live      string
+	RecordFor time.Duration
+
+	// Client sends every request; nil uses http.DefaultClient
+	Client *http.Client
+}
+
+// nextEngine cycles the Download Engine choice
+func nextEngine(engine string) string {
+	if engine == EngineNative {
+		return EngineYtDlp
+	}
+	return EngineNative
+}
+
+// engineLabel returns the display name of an engine
+func engineLabel(engine string) string {
+	if engine == EngineNative {
+		return "Native HLS"
+	}
+	return "yt-dlp"
+}
+
+// nextLiveMode cycles off → live edge → DVR start
+func nextLiveMode(mode string) string {
+	switch mode {
+	case LiveOff:
+		return LiveEdge
+	case LiveEdge:
+		return LiveStart
+	default:
+		return LiveOff
+	}
+}
+
+// liveModeLabel returns the display name of a live recording mode
+func liveModeLabel(mode string) string {
+	switch mode {
+	case LiveEdge:
+		return "From live edge"
+	case LiveStart:
+		return "From DVR start"
+	default:
+		return "Off"
+	}
+}
+
+// validateEngine rejects form options the native engine cannot honour
+func validateEngine(o DownloadOptions) error {
+	switch o.Engine {
+	case EngineYtDlp:
+		return nil
+	case EngineNative:
+	default:
+		return fmt.Errorf("Download engine must be yt-dlp or native")
+	}
+
+	unsupported := []struct {
+		set  bool
+		name string
+	}{
+		{o.Subtitles, "Subtitles"},
+		{o.Playlist, "Playlist Mode"},
+		{o.Detach, "Run in Background"},
+		{strings.TrimSpace(o.RateLimit) != "", "Rate Limit"},
+		{o.LowPriority, "Low CPU/IO Priority"},
+		{o.Archive != ArchiveOff, "Download Archive"},
+		{strings.TrimSpace(o.ExtraFlags) != "", "Extra Flags"},
+	}
+	for _, option := range unsupported {
+		if option.set {
+			return fmt.Errorf("%s needs the yt-dlp engine", option.name)
+		}
+	}
+
+	// Live recording
+	switch o.Live {
+	case LiveOff:
+		return nil
+	case LiveEdge, LiveStart:
+	default:
+		return fmt.Errorf("Live recording must be off, edge or start")
+	}
+	if limit := strings.TrimSpace(o.RecordFor); limit != "" && (!durationRe.MatchString(limit) || parseClock(limit) <= 0) {
+		return fmt.Errorf("Record for must be seconds or [h:]m:s, e.g. 90 or 1:30:00")
+	}
+	return nil
+}
+
+// newNativeJob maps the form options to a native engine job
+func newNativeJob(o DownloadOptions) *NativeJob {
+	job := &NativeJob{
+		URL:             strings.TrimSpace(o.URL),
+		Workers:         1,
+		Retries:         nativeRetryCount(o.FragmentRetries, nativeFragmentRetries),
+		PlaylistRetries: nativeRetryCount(o.Retries, nativeRetries),
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(o.Concurrent)); err == nil && n > 0 {
+		job.Workers = n
+	}
+
+	if seconds, err := strconv.ParseFloat(strings.TrimSpace(o.SocketTimeout), 64); err == nil {
+		job.Timeout = time.Duration(seconds * float64(time.Second))
+	}
+
+	job.Live = o.Live
+	if limit := strings.TrimSpace(o.RecordFor); job.Live != LiveOff && limit != "" {
+		job.RecordFor = parseClock(limit)
+	}
+	return job
+}
+
+// nativeRetryCount parses a retry field as validateRetries accepts it,
+// returning fallback when it is empty
+func nativeRetryCount(value string, fallback int) int {
+	value = strings.TrimSpace(value)
+	if value == "infinite" {
+		return nativeInfiniteRetries
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		return n
+	}
+	return fallback
+}
+
+// String describes the job in place of a command line
+func (j *NativeJob) String() string {
+	s := fmt.Sprintf("native HLS engine: %s (%d segments at once, %d retries per segment)", j.URL, j.Workers, j.Retries)
+	if j.Live != LiveOff {
+		s += ", recording " + strings.ToLower(liveModeLabel(j.Live))
+		if j.RecordFor > 0 {
+			s += " for " + formatClock(j.RecordFor)
+		}
+	}
+	return s
+}
+
+// downloader returns the HLS downloader configured for the job
+func (j *NativeJob) downloader() *hls.Downloader {
+	return &hls.Downloader{
+		Client:          j.Client,
+		Workers:         j.Workers,
+		Retries:         j.Retries,
+		PlaylistRetries: j.PlaylistRetries,
+		RetryDelay:      nativeRetryDelay,
+		Timeout:         j.Timeout,
+	}
+}
+
+// runNative downloads the session's native job into a single file,
+// reporting through the same messages as a yt-dlp run
+func (s *DownloadSession) runNative() {
+	defer close(s.msgs)
+
+	// Cancelling or pausing the session stops every request
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.cancelCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Nothing written or polled for StallTimeout stops the download; its
+	// single file cannot be continued, so it is not retried
+	var stalled atomic.Bool
+	s.touch()
+	if s.StallTimeout > 0 {
+		go s.watchdog(ctx.Done(), &stalled, cancel)
+	}
+
+	attempt := Attempt{Number: 1, Started: time.Now()}
+	complete := func(err error) {
+		attempt.Ended = time.Now()
+		msg := DownloadCompleteMsg{SessionID: s.ID, Success: err == nil}
+		if err != nil {
+			attempt.ExitCode = 1
+			msg.ExitCode = 1
+			msg.Failure = s.nativeFailure(err)
+			if stalled.Load() {
+				attempt.Stalled = true
+				msg.Failure = &Failure{Kind: FailureStalled, Message: fmt.Sprintf("no data for %s", s.StallTimeout)}
+			}
+		}
+		msg.Attempts = []Attempt{attempt}
+		s.msgs <- msg
+	}
+
+	job := s.Native
+	d := job.downloader()
+	s.msgs <- DownloadStageMsg{SessionID: s.ID, Stage: StageExtract, At: time.Now()}
+	s.msgs <- DownloadOutputMsg{SessionID: s.ID, Line: "[hls] Fetching " + job.URL}
+
+	media, variant, err := d.FetchMedia(ctx, job.URL)
+	if err != nil {
+		complete(err)
+		return
+	}
+	if variant != nil {
+		s.msgs <- DownloadOutputMsg{SessionID: s.ID, Line: fmt.Sprintf("[hls] Variant %s at %s/s (%s)",
+			variant.Resolution, formatBytes(variant.Bandwidth/8), strings.Join(variant.Codecs, ", "))}
+	}
+	s.msgs <- DownloadOutputMsg{SessionID: s.ID, Line: fmt.Sprintf("[hls] %d segments, %s",
+		len(media.Segments), formatClock(media.Duration()))}
+	recording := job.Live != LiveOff && media.Live()
+	switch {
+	case recording && len(media.Segments) == 0:
+		s.msgs <- DownloadOutputMsg{SessionID: s.ID, Line: "[hls] Live stream with no segments yet, waiting for it to begin"}
+	case recording:
+		s.msgs <- DownloadOutputMsg{SessionID: s.ID, Line: "[hls] Live stream, recording " + strings.ToLower(liveModeLabel(job.Live))}
+	case media.Live():
+		s.msgs <- DownloadOutputMsg{SessionID: s.ID, Line: "[hls] Live stream: only the segments listed now are downloaded; set Live Recording to keep recording"}
+	case job.Live != LiveOff:
+		s.msgs <- DownloadOutputMsg{SessionID: s.ID, Line: "[hls] The stream has ended, downloading all of it"}
+	}
+
+	// A live stream has no known duration yet
+	info := VideoInfo{
+		Title:      strings.TrimSuffix(path.Base(job.URL), path.Ext(job.URL)),
+		Duration:   media.Duration().Seconds(),
+		WebpageURL: job.URL,
+	}
+	if recording {
+		info.Duration = 0
+	}
+	s.msgs <- DownloadInfoMsg{SessionID: s.ID, Info: info}
+
+	// Refuse DRM and other unsupported streams before creating a file; a
+	// recording that waits for its first segments checks them in Record
+	if !recording || len(media.Segments) > 0 {
+		if err := d.Check(media); err != nil {
+			complete(err)
+			return
+		}
+	}
+
+	// Segments go to a .part file that is renamed once complete, with
+	// the extension of the segments actually written. It already has the
+	// unique name yt-dlp downloads are renamed to, so it is not renamed
+	// again.
+	folder := s.Folder
+	if folder == "" {
+		folder = "."
+	}
+	base := filepath.Join(folder, generateUniqueID(20))
+	part := base + ".part"
+	f, err := os.Create(part)
+	if err != nil {
+		complete(err)
+		return
+	}
+
+	s.msgs <- DownloadStageMsg{SessionID: s.ID, Stage: StageVideo, At: time.Now()}
+	fragmented := media.Fragmented()
+	d.OnProgress = func(p hls.Progress) {
+		fragmented = p.Fragmented
+		s.touch()
+		s.msgs <- DownloadProgressMsg{SessionID: s.ID, Progress: nativeProgress(p, job.RecordFor)}
+	}
+	d.OnRetry = func(seg hls.Segment, attempt int, err error) {
+		s.msgs <- DownloadOutputMsg{SessionID: s.ID, Line: fmt.Sprintf("[hls] Retrying segment %d (attempt %d): %v", seg.Sequence, attempt, err)}
+	}
+
+	var written int64
+	if job.Live != LiveOff {
+		written, err = d.Record(ctx, media, f, hls.LiveOptions{
+			FromStart:   job.Live == LiveStart,
+			MaxDuration: job.RecordFor,
+			Stop:        s.finishCh,
+		})
+		if err == nil && written == 0 {
+			err = fmt.Errorf("recording finished before any segment was written")
+		}
+	} else {
+		_, err = d.Download(ctx, media, f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	final := base + ".ts"
+	if fragmented {
+		final = base + ".mp4"
+	}
+	if err == nil {
+		err = os.Rename(part, final)
+	}
+	if err != nil {
+		// A cancelled or failed download leaves no partial file behind
+		os.Remove(part)
+		complete(err)
+		return
+	}
+
+	s.msgs <- DownloadFileMsg{SessionID: s.ID, Path: final}
+	complete(nil)
+}
+
+// nativeFailure classifies an error of the native engine; stopping on
+// request is not a failure
+func (s *DownloadSession) nativeFailure(err error) *Failure {
+	if s.stopping() {
+		return nil
+	}
+
+	var status *hls.StatusError
+	if errors.As(err, &status) {
+		switch status.StatusCode {
+		case http.StatusForbidden:
+			return &Failure{Kind: FailureHTTP403, Message: err.Error()}
+		case http.StatusNotFound:
+			return &Failure{Kind: FailureHTTP404, Message: err.Error()}
+		case http.StatusTooManyRequests:
+			return &Failure{Kind: FailureHTTP429, Message: err.Error()}
+		}
+	}
+	if errors.Is(err, hls.ErrUnsupportedEncryption) {
+		return &Failure{Kind: FailureDRM, Message: err.Error()}
+	}
+	if errors.Is(err, hls.ErrUnsupported) {
+		return &Failure{Kind: FailureUnsupportedURL, Message: err.Error()}
+	}
+	failure := ClassifyFailure([]string{err.Error()})
+	return &failure
+}
+
+// nativeProgress converts engine progress to the progress shown for
+// yt-dlp, with segments as fragments; a live recording reports its
+// recorded duration against limit instead
+func nativeProgress(p hls.Progress, limit time.Duration) Progress {
+	if p.Live {
+		progress := Progress{
+			Downloaded:  p.Bytes,
+			Speed:       p.Speed(),
+			Fragment:    p.Segment,
+			Live:        true,
+			Recorded:    p.Duration,
+			RecordLimit: limit,
+			Behind:      p.Behind,
+			Missed:      p.Missed,
+		}
+		if limit > 0 {
+			progress.Percent = min(float64(p.Duration)/float64(limit)*100, 100)
+		}
+		return progress
+	}
+
+	progress := Progress{
+		Downloaded:    p.Bytes,
+		Total:         p.EstimatedTotal(),
+		Speed:         p.Speed(),
+		Fragment:      p.Segment,
+		FragmentCount: p.Segments,
+		Finished:      p.Segment == p.Segments,
+	}
+	progress.TotalEstimated = !progress.Finished
+	if p.Segments > 0 {
+		progress.Percent = float64(p.Segment) / float64(p.Segments) * 100
+	}
+	if progress.Speed > 0 && !progress.Finished {
+		progress.ETA = time.Duration(float64(progress.Total-progress.Downloaded) / progress.Speed * float64(time.Second))
+		progress.HasETA = true
+	}
+	return progress
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pickerRows is how many playlist entries the picker shows at once
+const pickerRows = 12
+
+// Playlist item specs produced by the picker, e.g. "1-3,7,9-"
+var playlistItemsRe = regexp.MustCompile(`^\d+(-\d*)?(,\d+(-\d*)?)*$`)
+
+// PlaylistEntry is one video of a resolved playlist
+type PlaylistEntry struct {
+	Index    int
+	ID       string
+	Title    string
+	Duration float64
+	URL      string
+}
+
+// PlaylistResolvedMsg carries the flat listing of a playlist
+type PlaylistResolvedMsg struct {
+	Seq     int
+	Title   string
+	Entries []PlaylistEntry
+	Err     error
+}
+
+// PlaylistPicker is the state of the playlist entry checklist
+type PlaylistPicker struct {
+	Seq      int
+	Options  DownloadOptions
+	Loading  bool
+	Err      string
+	Title    string
+	Entries  []PlaylistEntry
+	Selected []bool
+	Cursor   int
+
+	// Range input, in the same syntax as the generated --playlist-items
+	EditingRange bool
+	RangeSpec    string
+}
+
+// flatPlaylist is the part of yt-dlp's -J output the picker needs
+type flatPlaylist struct {
+	Title   string `json:"title"`
+	Entries []struct {
+		ID         string   `json:"id"`
+		Title      string   `json:"title"`
+		Duration   *float64 `json:"duration"`
+		URL        string   `json:"url"`
+		WebpageURL string   `json:"webpage_url"`
+	} `json:"entries"`
+}
+
+// resolvePlaylist lists the entries of a playlist without downloading
+func resolvePlaylist(seq int, url string) tea.Cmd {
+	return func() tea.Msg {
+		msg := PlaylistResolvedMsg{Seq: seq}
+
+		ctx, cancel := context.WithTimeout(context.Background(), archiveProbeTimeout)
+		defer cancel()
+
+		output, err := exec.CommandContext(ctx, "yt-dlp", "--flat-playlist", "-J", url).Output()
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				lines := strings.Split(strings.TrimSpace(string(exitErr.Stderr)), "\n")
+				err = fmt.Errorf("%s", lines[len(lines)-1])
+			}
+			msg.Err = err
+			return msg
+		}
+
+		var playlist flatPlaylist
+		if err := json.Unmarshal(output, &playlist); err != nil {
+			msg.Err = fmt.Errorf("cannot read playlist listing: %w", err)
+			return msg
+		}
+
+		msg.Title = playlist.Title
+		for i, e := range playlist.Entries {
+			entry := PlaylistEntry{Index: i + 1, ID: e.ID, Title: e.Title, URL: e.WebpageURL}
+			if entry.URL == "" {
+				entry.URL = e.URL
+			}
+			if e.Duration != nil {
+				entry.Duration = *e.Duration
+			}
+			msg.Entries = append(msg.Entries, entry)
+		}
+		return msg
+	}
+}
+
+// SelectedCount returns how many entries are checked
+func (p *PlaylistPicker) SelectedCount() int {
+	n := 0
+	for _, s := range p.Selected {
+		if s {
+			n++
+		}
+	}
+	return n
+}
+
+// ItemSpec renders the checked entries as a compact --playlist-items value
+func (p *PlaylistPicker) ItemSpec() string {
+	var parts []string
+	for i := 0; i < len(p.Selected); i++ {
+		if !p.Selected[i] {
+			continue
+		}
+		j := i
+		for j+1 < len(p.Selected) && p.Selected[j+1] {
+			j++
+		}
+		if j == i {
+			parts = append(parts, strconv.Itoa(i+1))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", i+1, j+1))
+		}
+		i = j
+	}
+	return strings.Join(parts, ",")
+}
+
+// ApplyRange checks exactly the entries named by a spec like "1-5,8,10-"
+func (p *PlaylistPicker) ApplyRange(spec string) error {
+	spec = strings.ReplaceAll(spec, " ", "")
+	if !playlistItemsRe.MatchString(spec) {
+		return fmt.Errorf("Range must look like 1-5,8,10-")
+	}
+
+	selected := make([]bool, len(p.Entries))
+	for _, part := range strings.Split(spec, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		start, _ := strconv.Atoi(first)
+		end := start
+		if isRange {
+			end = len(p.Entries)
+			if last != "" {
+				end, _ = strconv.Atoi(last)
+			}
+		}
+		if start < 1 || start > len(p.Entries) || end < start {
+			return fmt.Errorf("Range %q is outside 1-%d", part, len(p.Entries))
+		}
+		for i := start; i <= min(end, len(p.Entries)); i++ {
+			selected[i-1] = true
+		}
+	}
+	p.Selected = selected
+	return nil
+}
+
+// ToggleAll checks every entry, or none if all are checked
+func (p *PlaylistPicker) ToggleAll() {
+	all := p.SelectedCount() == len(p.Selected)
+	for i := range p.Selected {
+		p.Selected[i] = !all
+	}
+}
+
+// openPicker validates the form and starts resolving its playlist URL
+func (m *Model) openPicker(url string) tea.Cmd {
+	opts := m.form
+	opts.URL = url
+	if err := ValidateInputs(opts); err != nil {
+		m.err = err.Error()
+		return nil
+	}
+	if _, err := BuildCommand(opts, m.features); err != nil {
+		m.err = err.Error()
+		return nil
+	}
+
+	m.pickerSeq++
+	m.picker = &PlaylistPicker{Seq: m.pickerSeq, Options: opts, Loading: true}
+	m.err = ""
+	return resolvePlaylist(m.pickerSeq, url)
+}
+
+// applyPlaylist fills the picker with a resolved listing; a URL that
+// turns out not to be a playlist is queued as it is
+func (m *Model) applyPlaylist(msg PlaylistResolvedMsg) tea.Cmd {
+	p := m.picker
+	if p == nil || p.Seq != msg.Seq {
+		return nil
+	}
+
+	p.Loading = false
+	if msg.Err != nil {
+		p.Err = msg.Err.Error()
+		return nil
+	}
+	if len(msg.Entries) == 0 {
+		m.picker = nil
+		return m.queueOptions([]DownloadOptions{p.Options})
+	}
+
+	p.Title = msg.Title
+	p.Entries = msg.Entries
+
+	// Start with the form's start/end range checked
+	first, _ := optionalCount("Start index", p.Options.PlaylistStart)
+	last, _ := optionalCount("End index", p.Options.PlaylistEnd)
+	if last == 0 {
+		last = len(p.Entries)
+	}
+	p.Selected = make([]bool, len(msg.Entries))
+	for i := range p.Selected {
+		p.Selected[i] = i+1 >= first && i+1 <= last
+	}
+	return nil
+}
+
+// queuePickerSelection queues the checked entries, either as one playlist
+// download limited with --playlist-items or as one item per entry
+func (m *Model) queuePickerSelection(individually bool) tea.Cmd {
+	p := m.picker
+	if p.SelectedCount() == 0 {
+		p.Err = "Select at least one entry"
+		return nil
+	}
+
+	var items []DownloadOptions
+	if individually {
+		for i, entry := range p.Entries {
+			if !p.Selected[i] || entry.URL == "" {
+				continue
+			}
+			opts := p.Options
+			opts.URL = entry.URL
+			opts.Playlist = false
+			items = append(items, opts)
+		}
+	} else {
+		opts := p.Options
+		if p.SelectedCount() < len(p.Entries) {
+			opts.PlaylistItems = p.ItemSpec()
+		}
+		items = append(items, opts)
+	}
+
+	m.picker = nil
+	m.form.URL = ""
+	m.SetCursorPos(FieldURL, 0)
+	return m.queueOptions(items)
+}
+
+// queueOptions appends validated option snapshots to the queue, shows
+// the queue and starts it
+func (m *Model) queueOptions(items []DownloadOptions) tea.Cmd {
+	first := len(m.queue)
+	for _, opts := range items {
+		m.queue = append(m.queue, &QueueItem{ID: m.nextQueueID, Options: opts, Status: QueuePending})
+		m.nextQueueID++
+	}
+	m.showQueue = true
+	m.queueCursor = first
+	return m.scheduleQueue()
+}
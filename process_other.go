@@ -0,0 +1,56 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on platforms without process groups
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command; children are not tracked on this
+// platform, so there is nothing to escalate to
+func killProcessGroup(cmd *exec.Cmd, exited <-chan struct{}) error {
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
+
+// interruptProcessGroup kills the command; there is no Ctrl+C equivalent here
+func interruptProcessGroup(cmd *exec.Cmd) error {
+	return killProcessGroup(cmd, nil)
+}
+
+// detachProcess is a no-op on platforms without sessions
+func detachProcess(cmd *exec.Cmd) {}
+
+// processAlive reports whether a process with pid still exists
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// terminateProcess kills the process with pid
+func terminateProcess(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
+
+// pauseProcess is unsupported without a signal to request a pause
+func pauseProcess(pid int) error {
+	return errors.New("pausing background downloads is not supported on this platform")
+}
+
+// notifyPause is a no-op on platforms without a pause signal
+func notifyPause(c chan<- os.Signal) {}
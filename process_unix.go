@@ -0,0 +1,85 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// killGracePeriod is how long a process group gets to exit after SIGTERM
+const killGracePeriod = 3 * time.Second
+
+// setProcessGroup starts the command in its own process group so that
+// yt-dlp and the ffmpeg children it spawns can be signalled together
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup terminates the command and all of its children,
+// escalating to SIGKILL if exited is not closed within the grace period.
+// exited must be closed once Wait returns; until then the group ID
+// cannot be reused by another process.
+func killProcessGroup(cmd *exec.Cmd, exited <-chan struct{}) error {
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+	if err := signalProcessGroup(cmd, syscall.SIGTERM); err != nil {
+		return err
+	}
+
+	pgid := cmd.Process.Pid
+	go func() {
+		select {
+		case <-exited:
+		case <-time.After(killGracePeriod):
+			syscall.Kill(-pgid, syscall.SIGKILL)
+		}
+	}()
+	return nil
+}
+
+// interruptProcessGroup stops the command and its children the way Ctrl+C
+// would, so yt-dlp leaves resumable .part files behind. There is no
+// escalation: yt-dlp needs the time to write its resume state.
+func interruptProcessGroup(cmd *exec.Cmd) error {
+	return signalProcessGroup(cmd, syscall.SIGINT)
+}
+
+// signalProcessGroup sends sig to the process group of the command
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
+
+// detachProcess starts the command in a new session so it keeps running
+// after the terminal that launched it closes
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// processAlive reports whether a process with pid still exists
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// terminateProcess asks the process with pid to shut down
+func terminateProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+// pauseProcess asks the supervisor with pid to pause its download
+func pauseProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGUSR1)
+}
+
+// notifyPause relays pause requests sent by pauseProcess to c
+func notifyPause(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
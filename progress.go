@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress is the parsed state of a yt-dlp [download] progress line
+type Progress struct {
+	Percent        float64
+	Downloaded     int64
+	Total          int64
+	TotalEstimated bool
+	Speed          float64 // bytes per second, 0 when unknown
+	ETA            time.Duration
+	HasETA         bool
+	Fragment       int
+	FragmentCount  int
+	Finished       bool
+
+	// Live recordings of the native engine: media recorded so far out
+	// of an optional limit, how far behind the live edge the recording
+	// is, and segments lost because they left the playlist unfetched
+	Live        bool
+	Recorded    time.Duration
+	RecordLimit time.Duration
+	Behind      time.Duration
+	Missed      int
+}
+
+// Patterns for the individual pieces of a [download] progress line
+var (
+	progressPercentRe    = regexp.MustCompile(`^\s*([\d.]+)%`)
+	progressTotalRe      = regexp.MustCompile(`\bof\s+(~)?\s*([\d.]+)\s*([KMGTPE]?i?B)\b`)
+	progressDownloadedRe = regexp.MustCompile(`^\s*([\d.]+)\s*([KMGTPE]?i?B)\s+at\b`)
+	progressSpeedRe      = regexp.MustCompile(`\bat\s+([\d.]+)\s*([KMGTPE]?i?B)/s`)
+	progressETARe        = regexp.MustCompile(`\bETA\s+(\d+(?::\d+)+)`)
+	progressElapsedRe    = regexp.MustCompile(`\bin\s+(\d+(?::\d+)+)`)
+	progressFragRe       = regexp.MustCompile(`\(frag\s+(\d+)/(\d+)\)`)
+)
+
+// ParseProgressLine parses a yt-dlp progress line such as
+// "[download]  45.0% of ~ 10.00MiB at 1.00MiB/s ETA 00:05 (frag 12/340)"
+func ParseProgressLine(line string) (Progress, bool) {
+	var p Progress
+
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), "[download]")
+	if !ok {
+		return p, false
+	}
+
+	if match := progressPercentRe.FindStringSubmatch(rest); match != nil {
+		pct, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return p, false
+		}
+		p.Percent = pct
+
+		if match := progressTotalRe.FindStringSubmatch(rest); match != nil {
+			p.TotalEstimated = match[1] == "~"
+			p.Total = parseSize(match[2], match[3])
+			p.Downloaded = int64(float64(p.Total) * pct / 100)
+		}
+	} else if match := progressDownloadedRe.FindStringSubmatch(rest); match != nil {
+		// Total size unknown, only the downloaded amount is reported
+		p.Downloaded = parseSize(match[1], match[2])
+	} else {
+		return p, false
+	}
+
+	if match := progressSpeedRe.FindStringSubmatch(rest); match != nil {
+		p.Speed = float64(parseSize(match[1], match[2]))
+	}
+
+	if match := progressETARe.FindStringSubmatch(rest); match != nil {
+		p.ETA = parseClock(match[1])
+		p.HasETA = true
+	}
+
+	if progressElapsedRe.MatchString(rest) {
+		p.Finished = true
+	}
+
+	if match := progressFragRe.FindStringSubmatch(rest); match != nil {
+		p.Fragment, _ = strconv.Atoi(match[1])
+		p.FragmentCount, _ = strconv.Atoi(match[2])
+	}
+
+	return p, true
+}
+
+// parseSize converts a number and a unit like "MiB" or "KB" to bytes
+func parseSize(number, unit string) int64 {
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0
+	}
+
+	base := 1000.0
+	if strings.Contains(unit, "i") {
+		base = 1024
+	}
+
+	exponent := strings.IndexByte("BKMGTPE", unit[0])
+	for i := 0; i < exponent; i++ {
+		value *= base
+	}
+	return int64(value)
+}
+
+// parseClock converts "SS", "MM:SS" or "HH:MM:SS" to a duration
+func parseClock(clock string) time.Duration {
+	var seconds int
+	for _, part := range strings.Split(clock, ":") {
+		n, _ := strconv.Atoi(part)
+		seconds = seconds*60 + n
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// formatBytes renders a byte count with binary units, as yt-dlp does
+func formatBytes(n int64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	value := float64(n)
+	exponent := -1
+	for value >= 1024 && exponent < len(units)-1 {
+		value /= 1024
+		exponent++
+	}
+	return fmt.Sprintf("%.2f%ciB", value, units[exponent])
+}
+
+// formatClock renders a duration as MM:SS or HH:MM:SS
+func formatClock(d time.Duration) string {
+	seconds := int(d.Round(time.Second) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseProgressLine(t *testing.T) {
+	const mib = 1024 * 1024
+
+	tests := []struct {
+		name string
+		line string
+		ok   bool
+		want Progress
+	}{
+		{
+			name: "fragments with estimated total",
+			line: "[download]  45.0% of ~ 10.00MiB at 1.2MiB/s ETA 00:07 (frag 12/340)",
+			ok:   true,
+			want: Progress{
+				Percent:        45,
+				Downloaded:     int64(10 * mib * 0.45),
+				Total:          10 * mib,
+				TotalEstimated: true,
+				Speed:          1258291, // 1.2MiB rounded down to bytes
+				ETA:            7 * time.Second,
+				HasETA:         true,
+				Fragment:       12,
+				FragmentCount:  340,
+			},
+		},
+		{
+			name: "finished",
+			line: "[download] 100% of 10.00MiB in 00:00:05",
+			ok:   true,
+			want: Progress{Percent: 100, Downloaded: 10 * mib, Total: 10 * mib, Finished: true},
+		},
+		{
+			name: "unknown speed and ETA",
+			line: "[download]   0.0% of   10.00MiB at  Unknown B/s ETA Unknown",
+			ok:   true,
+			want: Progress{Total: 10 * mib},
+		},
+		{
+			name: "size only",
+			line: "[download]   2.50MiB at  1.00MiB/s (00:00:02)",
+			ok:   true,
+			want: Progress{Downloaded: int64(2.5 * mib), Speed: mib},
+		},
+		{
+			name: "destination",
+			line: "[download] Destination: /tmp/video.f137.mp4",
+		},
+		{
+			name: "already downloaded",
+			line: "[download] /tmp/video.mp4 has already been downloaded",
+		},
+		{
+			name: "merger",
+			line: `[Merger] Merging formats into "/tmp/video.mp4"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseProgressLine(tt.line)
+			if ok != tt.ok {
+				t.Fatalf("ParseProgressLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			}
+			if ok && got != tt.want {
+				t.Errorf("ParseProgressLine(%q)\n got %+v\nwant %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// QueueStatus is the state of a queue item
+type QueueStatus int
+
+const (
+	QueuePending QueueStatus = iota
+	QueueRunning
+	QueueDone
+	QueueFailed
+	QueueCancelled
+	QueuePaused
+	QueueArchived
+)
+
+// String returns the display name of the status
+func (s QueueStatus) String() string {
+	switch s {
+	case QueuePending:
+		return "pending"
+	case QueueRunning:
+		return "running"
+	case QueueDone:
+		return "done"
+	case QueueFailed:
+		return "failed"
+	case QueueCancelled:
+		return "cancelled"
+	case QueuePaused:
+		return "paused"
+	case QueueArchived:
+		return "archived"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText stores the status by name in the persisted queue
+func (s QueueStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText parses a status name written by MarshalText
+func (s *QueueStatus) UnmarshalText(text []byte) error {
+	for status := QueuePending; status <= QueueArchived; status++ {
+		if status.String() == string(text) {
+			*s = status
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown queue status %q", text)
+}
+
+// Finished reports whether the item will not run again on its own
+func (s QueueStatus) Finished() bool {
+	return s == QueueDone || s == QueueFailed || s == QueueCancelled || s == QueueArchived
+}
+
+// QueueItem is one URL in the download queue with the form options that
+// were current when it was added
+type QueueItem struct {
+	ID        int             `json:"id"`
+	Options   DownloadOptions `json:"options"`
+	Status    QueueStatus     `json:"status"`
+	SessionID int             `json:"-"`
+	Err       string          `json:"error,omitempty"`
+
+	// Continue reruns yt-dlp with --continue to reuse partial files
+	Continue bool `json:"continue,omitempty"`
+	// JobDir is the supervisor directory of a detached item
+	JobDir string `json:"job_dir,omitempty"`
+	// Partials are the destination files whose partial files a paused
+	// item keeps, so cancelling it removes exactly those
+	Partials []string `json:"partials,omitempty"`
+	// Result is kept once the item finished
+	Result *QueueResult `json:"result,omitempty"`
+
+	// Scheduling order: pinned items first, then by priority, then by
+	// position in the queue
+	Priority QueuePriority `json:"priority,omitempty"`
+	Pinned   bool          `json:"pinned,omitempty"`
+
+	// Download archive check; Force downloads even if already archived
+	Probed       bool `json:"probed,omitempty"`
+	ArchiveTotal int  `json:"archive_total,omitempty"`
+	ArchiveHits  int  `json:"archive_hits,omitempty"`
+	Force        bool `json:"force,omitempty"`
+
+	// Size estimate from yt-dlp metadata, kept for reruns
+	Size int64 `json:"size,omitempty"`
+}
+
+// splitURLs splits the URL field into individual URLs, accepting any
+// mix of spaces and newlines as separators
+func splitURLs(field string) []string {
+	return strings.Fields(field)
+}
+
+// enqueueForm validates the form and appends one queue item per URL,
+// returning how many were added
+func (m *Model) enqueueForm() (int, error) {
+	urls := splitURLs(m.form.URL)
+	if len(urls) == 0 {
+		return 0, fmt.Errorf("URL cannot be empty")
+	}
+
+	// Validate every snapshot before adding any of them
+	var snapshots []DownloadOptions
+	for _, url := range urls {
+		opts := m.form
+		opts.URL = url
+		if err := ValidateInputs(opts); err != nil {
+			return 0, err
+		}
+		if _, err := BuildCommand(opts, m.features); err != nil {
+			return 0, err
+		}
+		snapshots = append(snapshots, opts)
+	}
+
+	for _, opts := range snapshots {
+		m.queue = append(m.queue, &QueueItem{ID: m.nextQueueID, Options: opts, Status: QueuePending})
+		m.nextQueueID++
+	}
+
+	// Clear the URL field for the next batch
+	m.form.URL = ""
+	m.SetCursorPos(FieldURL, 0)
+	return len(snapshots), nil
+}
+
+// scheduleQueue starts pending items in queue order until the job limit
+// is reached; running items are never stopped when the limit shrinks
+func (m *Model) scheduleQueue() tea.Cmd {
+	// Nothing starts until the user answers the resume prompt
+	if m.resumePrompt {
+		return nil
+	}
+
+	// Check archived videos before anything starts
+	cmds := []tea.Cmd{m.probeQueue()}
+	for m.countItems(QueueRunning) < m.jobLimit() {
+		item := m.nextPending()
+		if item == nil {
+			break
+		}
+		cmds = append(cmds, m.startItem(item))
+	}
+	return tea.Batch(cmds...)
+}
+
+// nextPending returns the pending item that should start next
+func (m Model) nextPending() *QueueItem {
+	var best *QueueItem
+	for _, item := range m.queue {
+		if item.Status != QueuePending || item.needsProbe() {
+			continue
+		}
+		if best == nil || item.Pinned && !best.Pinned ||
+			item.Pinned == best.Pinned && item.Priority > best.Priority {
+			best = item
+		}
+	}
+	return best
+}
+
+// jobLimit returns how many queue items may run at once, falling back to
+// one while the field is being edited
+func (m Model) jobLimit() int {
+	n, err := strconv.Atoi(strings.TrimSpace(m.parallelJobs))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return min(n, maxParallelJobs)
+}
+
+// adjustJobLimit changes the job limit by delta and fills any freed slots
+func (m *Model) adjustJobLimit(delta int) tea.Cmd {
+	n := max(1, min(m.jobLimit()+delta, maxParallelJobs))
+	m.parallelJobs = strconv.Itoa(n)
+	return m.scheduleQueue()
+}
+
+// Throughput returns the combined download speed of all running items
+func (m Model) Throughput() float64 {
+	total := 0.0
+	for _, item := range m.queue {
+		if item.Status != QueueRunning {
+			continue
+		}
+		if s := m.sessions[item.SessionID]; s != nil {
+			if p, ok := s.Progress(); ok && !p.Finished {
+				total += p.Speed
+			}
+		}
+	}
+	return total
+}
+
+// startItem builds the command for item and launches its download session
+func (m *Model) startItem(item *QueueItem) tea.Cmd {
+	opts := item.Options
+	archive := archivePath(opts)
+	if item.Force && archive != "" {
+		// yt-dlp would skip archived videos; they are recorded in a
+		// separate archive instead and merged in when the item finishes
+		opts.Archive = ArchiveOff
+		archive = forcedArchivePath(item.ID)
+	}
+
+	// yt-dlp creates the archive file but not its directory
+	if archive != "" {
+		os.MkdirAll(filepath.Dir(archive), 0o755)
+	}
+
+	cmd, err := BuildCommand(opts, m.features)
+	if err != nil {
+		item.Status = QueueFailed
+		item.Err = err.Error()
+		return nil
+	}
+	if opts.Archive != item.Options.Archive {
+		cmd = withArchive(cmd, archive)
+	}
+
+	s := NewDownloadSession(m.nextSessionID, cmd, strings.TrimSpace(item.Options.OutputFolder))
+	if seconds, err := strconv.Atoi(strings.TrimSpace(item.Options.StallTimeout)); err == nil {
+		s.StallTimeout = time.Duration(seconds) * time.Second
+	}
+	if opts.Engine == EngineNative {
+		s.Native = newNativeJob(opts)
+	} else if item.Continue {
+		s.Command = withContinue(s.Command)
+	}
+
+	var wait tea.Cmd
+	if item.Options.Detach {
+		wait, err = s.StartDetached()
+		if err != nil {
+			item.Status = QueueFailed
+			item.Err = err.Error()
+			return nil
+		}
+	} else {
+		wait = s.Start()
+	}
+
+	m.nextSessionID++
+	m.sessions[s.ID] = s
+	item.SessionID = s.ID
+	item.JobDir = s.JobDir
+	item.Status = QueueRunning
+	item.Pinned = false
+	item.Err = ""
+	item.Result = nil
+
+	return tea.Batch(wait, m.startTicking())
+}
+
+// finishItem records the outcome of the item whose session completed
+func (m *Model) finishItem(s *DownloadSession) {
+	item := m.itemForSession(s.ID)
+	if item == nil {
+		return
+	}
+
+	switch {
+	case s.Cancelled():
+		item.Status = QueueCancelled
+	case s.Succeeded():
+		item.Status = QueueDone
+	case s.Paused():
+		// Kept resumable; there is no result yet
+		item.Status = QueuePaused
+		item.Continue = true
+		for _, path := range s.Destinations() {
+			if !slices.Contains(item.Partials, path) {
+				item.Partials = append(item.Partials, path)
+			}
+		}
+		return
+	default:
+		item.Status = QueueFailed
+	}
+	item.Partials = nil
+
+	// Videos a forced run finished belong in the archive too
+	if item.Force && item.Options.Archive != ArchiveOff {
+		if err := mergeArchive(archivePath(item.Options), forcedArchivePath(item.ID)); err != nil {
+			s.AddOutputLine("Failed to update the download archive: " + err.Error())
+		}
+	}
+
+	item.Result = &QueueResult{
+		Started:  s.Started,
+		Bytes:    s.Downloaded(),
+		ExitCode: s.exitCode,
+		Attempts: len(s.Attempts()),
+		Failure:  s.Failure(),
+		Finished: time.Now(),
+	}
+	if info := s.Info(); info != nil {
+		item.Result.Title = info.Title
+	}
+}
+
+// cancelItem cancels a running item or drops a pending one; a paused
+// item also loses the partial files it kept for resuming
+func (m *Model) cancelItem(item *QueueItem) tea.Cmd {
+	switch item.Status {
+	case QueuePending:
+		item.Status = QueueCancelled
+	case QueueRunning:
+		if s := m.sessions[item.SessionID]; s != nil && !s.Cancelled() {
+			if err := s.Cancel(); err != nil {
+				s.AddOutputLine("Failed to cancel download: " + err.Error())
+			}
+		}
+	case QueuePaused:
+		item.Status = QueueCancelled
+		item.Continue = false
+		if s := m.sessions[item.SessionID]; s != nil {
+			s.Discard()
+			s.Dismiss()
+		}
+		// Only the item's own files; other jobs may share the folder
+		partials := item.Partials
+		item.Partials = nil
+		if len(partials) > 0 {
+			return cleanupPartialFiles(item.SessionID, partials)
+		}
+	}
+	return nil
+}
+
+// togglePause pauses a running or pending item, or puts a paused item
+// back in the queue to continue from its partial files
+func (m *Model) togglePause(item *QueueItem) tea.Cmd {
+	switch item.Status {
+	case QueuePending:
+		item.Status = QueuePaused
+	case QueueRunning:
+		// The native engine writes one file that cannot be continued
+		if item.Options.Engine == EngineNative {
+			m.err = "Native HLS downloads cannot be paused; cancel them, or finish a live recording with f"
+			return nil
+		}
+		if s := m.sessions[item.SessionID]; s != nil {
+			if err := s.Pause(); err != nil {
+				s.AddOutputLine("Failed to pause download: " + err.Error())
+			}
+		}
+	case QueuePaused:
+		// The paused session only holds the old log; a new one takes over
+		if s := m.sessions[item.SessionID]; s != nil {
+			s.Dismiss()
+			delete(m.sessions, s.ID)
+		}
+		item.SessionID = 0
+		item.JobDir = ""
+		item.Status = QueuePending
+		return m.scheduleQueue()
+	}
+	return nil
+}
+
+// finishRecordings ends the live recordings among items, keeping what
+// they recorded, and returns how many were asked to finish
+func (m *Model) finishRecordings(items []*QueueItem) int {
+	finished := 0
+	for _, item := range items {
+		if s := m.sessions[item.SessionID]; s != nil && item.Status == QueueRunning && s.FinishRecording() {
+			s.AddOutputLine("[hls] Finishing the recording")
+			finished++
+		}
+	}
+	return finished
+}
+
+// clearFinished removes finished items and their sessions from the queue
+func (m *Model) clearFinished() {
+	var kept []*QueueItem
+	for _, item := range m.queue {
+		if !item.Status.Finished() {
+			kept = append(kept, item)
+			continue
+		}
+		if s := m.sessions[item.SessionID]; s != nil {
+			s.Dismiss()
+			delete(m.sessions, s.ID)
+		}
+	}
+	m.setQueue(kept)
+}
+
+// itemForSession returns the queue item driving session id
+func (m Model) itemForSession(id int) *QueueItem {
+	for _, item := range m.queue {
+		if item.SessionID == id {
+			return item
+		}
+	}
+	return nil
+}
+
+// SelectedItem returns the queue item under the cursor
+func (m Model) SelectedItem() *QueueItem {
+	if m.queueCursor < 0 || m.queueCursor >= len(m.queue) {
+		return nil
+	}
+	return m.queue[m.queueCursor]
+}
+
+// countItems returns how many queue items have status
+func (m Model) countItems(status QueueStatus) int {
+	n := 0
+	for _, item := range m.queue {
+		if item.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// hasForegroundDownloads reports whether a running item would die with the TUI
+func (m Model) hasForegroundDownloads() bool {
+	for _, item := range m.queue {
+		if s := m.sessions[item.SessionID]; item.Status == QueueRunning && s != nil && !s.Detached() {
+			return true
+		}
+	}
+	return false
+}
+
+// itemLabel returns the video title once known, otherwise the URL
+func (m Model) itemLabel(item *QueueItem) string {
+	if s := m.sessions[item.SessionID]; s != nil {
+		if info := s.Info(); info != nil && info.Title != "" {
+			return info.Title
+		}
+	}
+	if item.Result != nil && item.Result.Title != "" {
+		return item.Result.Title
+	}
+	return item.Options.URL
+}
+
+// queueDetachedSessions adds reattached background sessions to the queue
+func (m *Model) queueDetachedSessions(sessions []*DownloadSession) {
+	for _, s := range sessions {
+		m.sessions[s.ID] = s
+		opts := m.form
+		opts.URL = s.Command.Args[len(s.Command.Args)-1]
+		opts.OutputFolder = s.Folder
+		opts.Detach = true
+		m.queue = append(m.queue, &QueueItem{ID: m.nextQueueID, Options: opts, Status: QueueRunning, SessionID: s.ID, JobDir: s.JobDir})
+		m.nextQueueID++
+		if s.ID >= m.nextSessionID {
+			m.nextSessionID = s.ID + 1
+		}
+	}
+}
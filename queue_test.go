@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestClearFinishedPrunesMarks(t *testing.T) {
+	m := Model{sessions: make(map[int]*DownloadSession), marked: make(map[int]bool)}
+	for id, status := range []QueueStatus{QueuePending, QueueDone, QueueFailed, QueuePaused} {
+		m.queue = append(m.queue, &QueueItem{ID: id + 1, Status: status})
+	}
+	m.queueCursor = 3
+	m.toggleMarkAll()
+
+	m.clearFinished()
+	if len(m.queue) != 2 || len(m.marked) != 2 || !m.marked[1] || !m.marked[4] {
+		t.Fatalf("queue %d items, marks %v; want the pending and paused items marked", len(m.queue), m.marked)
+	}
+	if m.queueCursor != 1 {
+		t.Errorf("cursor = %d, want 1", m.queueCursor)
+	}
+
+	// Every remaining item is marked, so the toggle clears them
+	m.toggleMarkAll()
+	if len(m.marked) != 0 {
+		t.Errorf("marks = %v after toggling all, want none", m.marked)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// QueuePriority orders pending items; higher runs first
+type QueuePriority int
+
+const (
+	PriorityLow QueuePriority = iota - 1
+	PriorityNormal
+	PriorityHigh
+)
+
+// String returns the display name of the priority
+func (p QueuePriority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// MarshalText stores the priority by name in the persisted queue
+func (p QueuePriority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText parses a priority name written by MarshalText
+func (p *QueuePriority) UnmarshalText(text []byte) error {
+	for priority := PriorityLow; priority <= PriorityHigh; priority++ {
+		if priority.String() == string(text) {
+			*p = priority
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown queue priority %q", text)
+}
+
+// targetItems returns the marked items in queue order, or the item under
+// the cursor when nothing is marked
+func (m Model) targetItems() []*QueueItem {
+	var items []*QueueItem
+	for _, item := range m.queue {
+		if m.marked[item.ID] {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		if item := m.SelectedItem(); item != nil {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// toggleMark marks or unmarks the item under the cursor
+func (m *Model) toggleMark() {
+	if item := m.SelectedItem(); item != nil {
+		if m.marked[item.ID] {
+			delete(m.marked, item.ID)
+		} else {
+			m.marked[item.ID] = true
+		}
+	}
+}
+
+// toggleMarkAll marks every item, or clears the marks if all are marked
+func (m *Model) toggleMarkAll() {
+	if len(m.marked) == len(m.queue) {
+		clear(m.marked)
+		return
+	}
+	for _, item := range m.queue {
+		m.marked[item.ID] = true
+	}
+}
+
+// moveItem moves the item under the cursor by delta positions
+func (m *Model) moveItem(delta int) {
+	i, j := m.queueCursor, m.queueCursor+delta
+	if i < 0 || i >= len(m.queue) || j < 0 || j >= len(m.queue) {
+		return
+	}
+	m.queue[i], m.queue[j] = m.queue[j], m.queue[i]
+	m.queueCursor = j
+}
+
+// togglePin pins the targeted pending items so they start next, moving
+// them to the top of the queue, or unpins them
+func (m *Model) togglePin() {
+	var waiting []*QueueItem
+	pin := false
+	for _, item := range m.targetItems() {
+		if item.Status == QueuePending || item.Status == QueuePaused {
+			waiting = append(waiting, item)
+			pin = pin || !item.Pinned
+		}
+	}
+	for _, item := range waiting {
+		item.Pinned = pin
+	}
+	if !pin {
+		return
+	}
+
+	// Keep pinned items together at the top, in their current order
+	current := m.SelectedItem()
+	rest := make([]*QueueItem, 0, len(m.queue))
+	for _, item := range m.queue {
+		if !item.Pinned || item.Status == QueueRunning {
+			rest = append(rest, item)
+		}
+	}
+	var top []*QueueItem
+	for _, item := range m.queue {
+		if item.Pinned && item.Status != QueueRunning {
+			top = append(top, item)
+		}
+	}
+	m.queue = append(top, rest...)
+	m.selectItem(current)
+}
+
+// adjustPriority raises or lowers the priority of the targeted items
+func (m *Model) adjustPriority(delta int) {
+	for _, item := range m.targetItems() {
+		p := item.Priority + QueuePriority(delta)
+		item.Priority = max(PriorityLow, min(p, PriorityHigh))
+	}
+}
+
+// retryItems puts failed or cancelled items back in the queue with their
+// original option snapshot
+func (m *Model) retryItems(items []*QueueItem) tea.Cmd {
+	for _, item := range items {
+		if item.Status != QueueFailed && item.Status != QueueCancelled {
+			continue
+		}
+		if s := m.sessions[item.SessionID]; s != nil {
+			s.Dismiss()
+			delete(m.sessions, s.ID)
+		}
+		item.SessionID = 0
+		item.JobDir = ""
+		item.Err = ""
+		item.Result = nil
+		item.Status = QueuePending
+	}
+	clear(m.marked)
+	return m.scheduleQueue()
+}
+
+// failedItems returns every failed item in the queue
+func (m Model) failedItems() []*QueueItem {
+	var items []*QueueItem
+	for _, item := range m.queue {
+		if item.Status == QueueFailed {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// removeItems drops the targeted items from the queue; running items have
+// to be cancelled or paused first and are kept. Paused items are cancelled
+// so their partial files are removed too. It returns how many were kept
+func (m *Model) removeItems(items []*QueueItem) (int, tea.Cmd) {
+	remove := make(map[int]bool)
+	kept := 0
+	var cmds []tea.Cmd
+	for _, item := range items {
+		if item.Status == QueueRunning {
+			kept++
+			continue
+		}
+		if item.Status == QueuePaused {
+			cmds = append(cmds, m.cancelItem(item))
+		}
+		remove[item.ID] = true
+		if s := m.sessions[item.SessionID]; s != nil {
+			s.Dismiss()
+			delete(m.sessions, s.ID)
+		}
+	}
+
+	var queue []*QueueItem
+	for _, item := range m.queue {
+		if !remove[item.ID] {
+			queue = append(queue, item)
+		}
+	}
+	m.setQueue(queue)
+	clear(m.marked)
+	return kept, tea.Batch(cmds...)
+}
+
+// setQueue replaces the queue with items that remain of it, dropping
+// the marks of the items that left and keeping the cursor in range
+func (m *Model) setQueue(items []*QueueItem) {
+	m.queue = items
+	kept := make(map[int]bool, len(items))
+	for _, item := range items {
+		kept[item.ID] = true
+	}
+	for id := range m.marked {
+		if !kept[id] {
+			delete(m.marked, id)
+		}
+	}
+	m.queueCursor = max(0, min(m.queueCursor, len(m.queue)-1))
+}
+
+// cancelItems cancels every targeted item
+func (m *Model) cancelItems(items []*QueueItem) tea.Cmd {
+	var cmds []tea.Cmd
+	for _, item := range items {
+		cmds = append(cmds, m.cancelItem(item))
+	}
+	clear(m.marked)
+	return tea.Batch(cmds...)
+}
+
+// selectItem moves the cursor to item
+func (m *Model) selectItem(item *QueueItem) {
+	for i, it := range m.queue {
+		if it == item {
+			m.queueCursor = i
+			return
+		}
+	}
+}
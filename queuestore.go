@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// queueStateFile is the name of the persisted queue inside the data directory
+const queueStateFile = "queue.json"
+
+// QueueResult is the outcome of a finished queue item, kept across restarts
+type QueueResult struct {
+	Title    string    `json:"title,omitempty"`
+	ExitCode int       `json:"exit_code"`
+	Attempts int       `json:"attempts"`
+	Failure  *Failure  `json:"failure,omitempty"`
+	Finished time.Time `json:"finished"`
+	Started  time.Time `json:"started,omitempty"`
+	Bytes    int64     `json:"bytes,omitempty"`
+}
+
+// QueueState is the persisted form of the download queue
+type QueueState struct {
+	ParallelJobs string       `json:"parallel_jobs"`
+	NextID       int          `json:"next_id"`
+	Items        []*QueueItem `json:"items"`
+}
+
+// dataDir returns the per-user directory for state that outlives a reboot
+func dataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "hlsdownloader")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "share", "hlsdownloader")
+	}
+	return filepath.Join(os.TempDir(), "hlsdownloader-data")
+}
+
+// queueStatePath returns the path of the persisted queue
+func queueStatePath() string {
+	return filepath.Join(dataDir(), queueStateFile)
+}
+
+// LoadQueueState reads the queue saved by a previous run; a missing or
+// unreadable file yields an empty state
+func LoadQueueState() QueueState {
+	var state QueueState
+	data, err := os.ReadFile(queueStatePath())
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return QueueState{}
+	}
+	return state
+}
+
+// queueSnapshot encodes the parts of the model that make up the queue
+func (m Model) queueSnapshot() ([]byte, error) {
+	state := QueueState{
+		ParallelJobs: m.parallelJobs,
+		NextID:       m.nextQueueID,
+		Items:        m.queue,
+	}
+	return json.MarshalIndent(state, "", "  ")
+}
+
+// saveQueue writes the queue to disk when it changed since the last write.
+// Progress is not part of the snapshot, so this only writes on real changes
+func (m *Model) saveQueue() {
+	data, err := m.queueSnapshot()
+	if err != nil || string(data) == m.savedQueue {
+		return
+	}
+
+	if err := os.MkdirAll(dataDir(), 0o755); err != nil {
+		return
+	}
+	if err := writeFileAtomic(queueStatePath(), data); err != nil {
+		return
+	}
+	m.savedQueue = string(data)
+}
+
+// restoreQueue rebuilds the queue saved by a previous run. Detached items
+// reattach to their supervisor; items that were running in the old
+// process are marked to continue from their partial files. It returns
+// whether any item is waiting to be resumed
+func (m *Model) restoreQueue(state QueueState, detached []*DownloadSession) bool {
+	if state.ParallelJobs != "" {
+		m.parallelJobs = state.ParallelJobs
+	}
+
+	byDir := make(map[string]*DownloadSession)
+	for _, s := range detached {
+		byDir[s.JobDir] = s
+	}
+
+	resumable := false
+	for _, item := range state.Items {
+		if item == nil {
+			continue
+		}
+		item.SessionID = 0
+
+		if s := byDir[item.JobDir]; item.JobDir != "" && s != nil {
+			// The supervisor kept it going (or finished it) while we were gone
+			delete(byDir, item.JobDir)
+			m.sessions[s.ID] = s
+			item.SessionID = s.ID
+			item.Status = QueueRunning
+		} else if item.Status == QueueRunning {
+			// The process died with the previous run; pick up the .part files
+			item.Status = QueuePending
+			item.Continue = true
+			item.JobDir = ""
+		}
+
+		if item.Status == QueuePending {
+			resumable = true
+		}
+		m.queue = append(m.queue, item)
+	}
+	m.nextQueueID = max(m.nextQueueID, state.NextID)
+
+	// Jobs the saved queue doesn't know about still get an entry
+	var unknown []*DownloadSession
+	for _, s := range detached {
+		if _, ok := byDir[s.JobDir]; ok {
+			unknown = append(unknown, s)
+		}
+	}
+	m.queueDetachedSessions(unknown)
+
+	return resumable
+}
+
+// discardPending drops the restored items a declined resume prompt left
+// waiting; items added since launch, e.g. by --batch-file, stay
+func (m *Model) discardPending() {
+	var kept []*QueueItem
+	for _, item := range m.queue {
+		if item.Status != QueuePending || item.ID >= m.firstNewID {
+			kept = append(kept, item)
+		}
+	}
+	m.setQueue(kept)
+}
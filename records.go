@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Prefixes of the machine-readable records emitted through
+// --progress-template and --print
+const (
+	recordProgress    = "HLSDL-PROGRESS "
+	recordPostprocess = "HLSDL-POSTPROCESS "
+	recordInfo        = "HLSDL-INFO "
+	recordFile        = "HLSDL-FILE "
+	recordRetry       = "HLSDL-RETRY "
+)
+
+// VideoInfo is the metadata printed by yt-dlp before a video downloads
+type VideoInfo struct {
+	ID             string  `json:"id"`
+	Title          string  `json:"title"`
+	Extractor      string  `json:"extractor_key"`
+	Duration       float64 `json:"duration"`
+	Filesize       int64   `json:"filesize"`
+	FilesizeApprox int64   `json:"filesize_approx"`
+	WebpageURL     string  `json:"webpage_url"`
+}
+
+// Size returns the exact file size if known, otherwise the estimate
+func (v VideoInfo) Size() int64 {
+	if v.Filesize > 0 {
+		return v.Filesize
+	}
+	return v.FilesizeApprox
+}
+
+// DownloadProgressMsg carries a structured progress record
+type DownloadProgressMsg struct {
+	SessionID int
+	Progress  Progress
+	Filename  string
+}
+
+// DownloadPostprocessMsg reports a yt-dlp postprocessor status change
+type DownloadPostprocessMsg struct {
+	SessionID     int
+	Postprocessor string
+	Status        string
+}
+
+// DownloadInfoMsg carries the metadata of the video being downloaded
+type DownloadInfoMsg struct {
+	SessionID int
+	Info      VideoInfo
+}
+
+// DownloadFileMsg reports the final path of a downloaded file
+type DownloadFileMsg struct {
+	SessionID int
+	Path      string
+}
+
+// progressRecord mirrors the progress dict yt-dlp passes to its hooks
+type progressRecord struct {
+	Status             string   `json:"status"`
+	Filename           string   `json:"filename"`
+	DownloadedBytes    int64    `json:"downloaded_bytes"`
+	TotalBytes         int64    `json:"total_bytes"`
+	TotalBytesEstimate float64  `json:"total_bytes_estimate"`
+	Speed              *float64 `json:"speed"`
+	ETA                *float64 `json:"eta"`
+	FragmentIndex      int      `json:"fragment_index"`
+	FragmentCount      int      `json:"fragment_count"`
+}
+
+// postprocessRecord mirrors the postprocessor hook dict
+type postprocessRecord struct {
+	Status        string `json:"status"`
+	Postprocessor string `json:"postprocessor"`
+}
+
+// recordTemplateArgs returns the yt-dlp options that emit structured records
+func recordTemplateArgs() []string {
+	return []string{
+		"--progress-template", "download:" + recordProgress + "%(progress)j",
+		"--progress-template", "postprocess:" + recordPostprocess + "%(progress)j",
+		"--print", "video:" + recordInfo + "%(.{id,title,extractor_key,duration,filesize,filesize_approx,webpage_url})j",
+		"--print", "after_move:" + recordFile + "%(filepath)s",
+	}
+}
+
+// parseOutputLine turns a line of yt-dlp output into a typed message,
+// falling back to a plain DownloadOutputMsg for ordinary log lines
+func parseOutputLine(sessionID int, line string) tea.Msg {
+	switch {
+	case strings.HasPrefix(line, recordProgress):
+		var rec progressRecord
+		if err := json.Unmarshal([]byte(line[len(recordProgress):]), &rec); err == nil {
+			return DownloadProgressMsg{SessionID: sessionID, Progress: rec.toProgress(), Filename: rec.Filename}
+		}
+
+	case strings.HasPrefix(line, recordPostprocess):
+		var rec postprocessRecord
+		if err := json.Unmarshal([]byte(line[len(recordPostprocess):]), &rec); err == nil {
+			return DownloadPostprocessMsg{SessionID: sessionID, Postprocessor: rec.Postprocessor, Status: rec.Status}
+		}
+
+	case strings.HasPrefix(line, recordInfo):
+		var info VideoInfo
+		if err := json.Unmarshal([]byte(line[len(recordInfo):]), &info); err == nil {
+			return DownloadInfoMsg{SessionID: sessionID, Info: info}
+		}
+
+	case strings.HasPrefix(line, recordFile):
+		return DownloadFileMsg{SessionID: sessionID, Path: strings.TrimSpace(line[len(recordFile):])}
+
+	case strings.HasPrefix(line, recordRetry):
+		var msg DownloadRetryMsg
+		if err := json.Unmarshal([]byte(line[len(recordRetry):]), &msg); err == nil {
+			msg.SessionID = sessionID
+			return msg
+		}
+	}
+
+	return DownloadOutputMsg{SessionID: sessionID, Line: line}
+}
+
+// formatRetryRecord renders a retry notice as a record line for job logs
+func formatRetryRecord(msg DownloadRetryMsg) string {
+	data, _ := json.Marshal(msg)
+	return recordRetry + string(data)
+}
+
+// toProgress converts a progress record to the shared Progress type
+func (r progressRecord) toProgress() Progress {
+	p := Progress{
+		Downloaded:    r.DownloadedBytes,
+		Total:         r.TotalBytes,
+		Fragment:      r.FragmentIndex,
+		FragmentCount: r.FragmentCount,
+		Finished:      r.Status == "finished",
+	}
+
+	if p.Total == 0 && r.TotalBytesEstimate > 0 {
+		p.Total = int64(r.TotalBytesEstimate)
+		p.TotalEstimated = true
+	}
+	if p.Total > 0 {
+		p.Percent = float64(p.Downloaded) / float64(p.Total) * 100
+	}
+	if r.Speed != nil {
+		p.Speed = *r.Speed
+	}
+	if r.ETA != nil {
+		p.ETA = time.Duration(*r.ETA * float64(time.Second))
+		p.HasETA = true
+	}
+
+	return p
+}
@@ -0,0 +1,643 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Number of stderr lines kept for failure classification
+const stderrTailSize = 200
+
+// Retry policy for downloads that stop producing output
+const (
+	maxStallRetries  = 5
+	stallBackoffBase = 5 * time.Second
+	stallBackoffMax  = 2 * time.Minute
+)
+
+// maxLineSize bounds one line of yt-dlp output; --print records with a
+// full format list or a long description run to megabytes
+const maxLineSize = 64 << 20
+
+// exitMaxDownloads is yt-dlp's exit code when --max-downloads stops it
+const exitMaxDownloads = 101
+
+// Attempt records one run of yt-dlp within a session
+type Attempt struct {
+	Number   int
+	Started  time.Time
+	Ended    time.Time
+	ExitCode int
+	Stalled  bool
+}
+
+// DownloadRetryMsg is sent when a stalled attempt is killed and will be retried
+type DownloadRetryMsg struct {
+	SessionID int
+	Attempt   int
+	Delay     time.Duration
+	Reason    string
+}
+
+// DownloadSession owns a single yt-dlp run: its process, message stream,
+// collected output and final result
+type DownloadSession struct {
+	ID      int
+	Command Command
+	Folder  string
+	Started time.Time
+
+	// StallTimeout kills and restarts yt-dlp when no output arrives for
+	// this long; zero disables the watchdog
+	StallTimeout time.Duration
+
+	// JobDir is set when the download runs under a background supervisor
+	JobDir string
+
+	// Native is set when the built-in HLS engine runs instead of yt-dlp
+	Native *NativeJob
+
+	// Result state, only touched from the Update loop
+	output         []string
+	progress       Progress
+	hasProgress    bool
+	bytesDone      int64
+	info           *VideoInfo
+	files          []string
+	timeline       *Timeline
+	success        *bool
+	exitCode       int
+	attempts       []Attempt
+	retry          *DownloadRetryMsg
+	failure        *Failure
+	partialRemoved int
+
+	// Streaming state
+	msgs         chan tea.Msg
+	mu           sync.Mutex
+	proc         *exec.Cmd
+	exited       chan struct{}
+	cancelled    bool
+	paused       bool
+	cancelCh     chan struct{}
+	finishing    bool
+	finishCh     chan struct{}
+	lastActivity atomic.Int64
+	stderrTail   []string
+	destinations []string
+	detector     stageDetector
+	onLine       func(line string)
+}
+
+// NewDownloadSession creates a session for cmd writing into folder
+func NewDownloadSession(id int, cmd Command, folder string) *DownloadSession {
+	return &DownloadSession{
+		ID:       id,
+		Command:  cmd,
+		Folder:   folder,
+		output:   []string{},
+		msgs:     make(chan tea.Msg, 100),
+		cancelCh: make(chan struct{}),
+		finishCh: make(chan struct{}),
+	}
+}
+
+// Start launches yt-dlp and returns a command delivering its first message
+func (s *DownloadSession) Start() tea.Cmd {
+	s.Started = time.Now()
+	s.timeline = NewTimeline(s.Started)
+	if s.Native != nil {
+		go s.runNative()
+	} else {
+		go s.streamDownloadOutput()
+	}
+	return s.Wait()
+}
+
+// Describe returns the command line, or the native job, of the session
+func (s *DownloadSession) Describe() string {
+	if s.Native != nil {
+		return s.Native.String()
+	}
+	return s.Command.String()
+}
+
+// Wait returns a command that delivers the next message of the session
+func (s *DownloadSession) Wait() tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-s.msgs
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// Cancel terminates the yt-dlp process group of the session and stops
+// any pending retry
+func (s *DownloadSession) Cancel() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.cancelled && !s.paused {
+		close(s.cancelCh)
+	}
+	s.cancelled = true
+	if s.Detached() {
+		return s.cancelDetached()
+	}
+	return killProcessGroup(s.proc, s.exited)
+}
+
+// Pause stops the session like Ctrl+C would, keeping its partial files
+// so a later run with --continue picks up where it left off
+func (s *DownloadSession) Pause() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancelled || s.paused {
+		return nil
+	}
+	s.paused = true
+	close(s.cancelCh)
+	if s.Detached() {
+		return s.pauseDetached()
+	}
+	return interruptProcessGroup(s.proc)
+}
+
+// FinishRecording ends a live recording early, keeping what was
+// recorded; it reports false for sessions that are not recording
+func (s *DownloadSession) FinishRecording() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.Recording() || s.cancelled || s.paused || s.finishing {
+		return false
+	}
+	s.finishing = true
+	close(s.finishCh)
+	return true
+}
+
+// Recording reports whether the session records a live stream
+func (s *DownloadSession) Recording() bool {
+	return s.Native != nil && s.Native.Live != LiveOff
+}
+
+// Finishing reports whether the user asked to end the recording
+func (s *DownloadSession) Finishing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.finishing
+}
+
+// Discard marks a paused session cancelled once its partial files are
+// no longer wanted
+func (s *DownloadSession) Discard() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelled = true
+}
+
+// Running reports whether the session has not finished yet
+func (s *DownloadSession) Running() bool {
+	return s.success == nil
+}
+
+// Succeeded reports whether the session finished successfully
+func (s *DownloadSession) Succeeded() bool {
+	return s.success != nil && *s.success
+}
+
+// Cancelled reports whether the user cancelled the session
+func (s *DownloadSession) Cancelled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cancelled
+}
+
+// Paused reports whether the user paused the session
+func (s *DownloadSession) Paused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// stopping reports whether the session was cancelled or paused
+func (s *DownloadSession) stopping() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cancelled || s.paused
+}
+
+// Finish records the final result of the session
+func (s *DownloadSession) Finish(msg DownloadCompleteMsg) {
+	success := msg.Success && !s.Cancelled()
+	s.success = &success
+	s.exitCode = msg.ExitCode
+	s.attempts = msg.Attempts
+	s.failure = msg.Failure
+	s.retry = nil
+}
+
+// Failure returns the classified reason of a failed session
+func (s *DownloadSession) Failure() *Failure {
+	return s.failure
+}
+
+// SetRetry records that a stalled attempt is about to be retried
+func (s *DownloadSession) SetRetry(msg DownloadRetryMsg) {
+	s.retry = &msg
+	s.AddOutputLine(fmt.Sprintf("Stalled: %s, retrying in %s (attempt %d of %d)",
+		msg.Reason, msg.Delay, msg.Attempt, maxStallRetries+1))
+}
+
+// Retry returns the latest retry notice, if the session was restarted
+func (s *DownloadSession) Retry() *DownloadRetryMsg {
+	return s.retry
+}
+
+// Attempts returns the attempts recorded in the final result
+func (s *DownloadSession) Attempts() []Attempt {
+	return s.attempts
+}
+
+// AddOutputLine adds a line to the session output, updating progress
+// when the line is a yt-dlp progress report
+func (s *DownloadSession) AddOutputLine(line string) {
+	line = strings.TrimSpace(line)
+	s.output = append(s.output, line)
+
+	if p, ok := ParseProgressLine(line); ok {
+		s.progress = p
+		s.hasProgress = true
+	}
+}
+
+// Timeline returns the stage timeline of the session
+func (s *DownloadSession) Timeline() *Timeline {
+	return s.timeline
+}
+
+// SetProgress records a structured progress report
+func (s *DownloadSession) SetProgress(p Progress) {
+	// Count each finished file once
+	if p.Finished && !(s.hasProgress && s.progress.Finished) {
+		s.bytesDone += max(p.Downloaded, p.Total)
+	}
+	s.progress = p
+	s.hasProgress = true
+}
+
+// Downloaded returns the bytes fetched so far across all files
+func (s *DownloadSession) Downloaded() int64 {
+	if s.hasProgress && !s.progress.Finished {
+		return s.bytesDone + s.progress.Downloaded
+	}
+	return s.bytesDone
+}
+
+// SetInfo records the metadata of the video being downloaded
+func (s *DownloadSession) SetInfo(info VideoInfo) {
+	s.info = &info
+}
+
+// Info returns the metadata of the video being downloaded, if known
+func (s *DownloadSession) Info() *VideoInfo {
+	return s.info
+}
+
+// AddFile records the final path of a downloaded file
+func (s *DownloadSession) AddFile(path string) {
+	s.files = append(s.files, path)
+}
+
+// Progress returns the latest parsed progress, if any was reported
+func (s *DownloadSession) Progress() (Progress, bool) {
+	return s.progress, s.hasProgress
+}
+
+// LastOutputLines returns last n lines of raw output
+func (s *DownloadSession) LastOutputLines(n int) []string {
+	if len(s.output) <= n {
+		return s.output
+	}
+	return s.output[len(s.output)-n:]
+}
+
+// LastLogLines returns last n lines of output that are not progress reports
+func (s *DownloadSession) LastLogLines(n int) []string {
+	var lines []string
+	for i := len(s.output) - 1; i >= 0 && len(lines) < n; i-- {
+		if _, ok := ParseProgressLine(s.output[i]); !ok {
+			lines = append(lines, s.output[i])
+		}
+	}
+
+	// Restore chronological order
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+// streamDownloadOutput runs yt-dlp and streams output line by line,
+// restarting it with --continue when the watchdog detects a stall
+func (s *DownloadSession) streamDownloadOutput() {
+	defer close(s.msgs)
+
+	if s.Command.IsEmpty() {
+		s.msgs <- DownloadCompleteMsg{SessionID: s.ID, Success: false, ExitCode: 1}
+		return
+	}
+
+	var attempts []Attempt
+	cmd := s.Command
+	for {
+		attempt := Attempt{Number: len(attempts) + 1, Started: time.Now()}
+		attempt.ExitCode, attempt.Stalled = s.runAttempt(cmd)
+		attempt.Ended = time.Now()
+		attempts = append(attempts, attempt)
+
+		// yt-dlp exits with 101 once --max-downloads is reached
+		stopped := attempt.ExitCode == exitMaxDownloads && cmd.HasFlag("--max-downloads")
+		complete := DownloadCompleteMsg{
+			SessionID: s.ID,
+			Success:   (attempt.ExitCode == 0 || stopped) && !attempt.Stalled,
+			ExitCode:  attempt.ExitCode,
+			Attempts:  attempts,
+		}
+		if !complete.Success {
+			complete.Failure = s.classifyFailure(attempt)
+		}
+		if !attempt.Stalled || s.stopping() || len(attempts) > maxStallRetries {
+			s.msgs <- complete
+			return
+		}
+
+		delay := stallBackoff(len(attempts))
+		s.msgs <- DownloadRetryMsg{
+			SessionID: s.ID,
+			Attempt:   len(attempts) + 1,
+			Delay:     delay,
+			Reason:    fmt.Sprintf("no output for %s", s.StallTimeout),
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-s.cancelCh:
+			s.msgs <- complete
+			return
+		}
+		cmd = withContinue(s.Command)
+	}
+}
+
+// runAttempt runs cmd once, returning its exit code and whether the
+// watchdog killed it for not producing output
+func (s *DownloadSession) runAttempt(cmd Command) (int, bool) {
+	execCmd := cmd.Exec()
+	setProcessGroup(execCmd)
+
+	stdout, err := execCmd.StdoutPipe()
+	if err != nil {
+		return 1, false
+	}
+
+	stderr, err := execCmd.StderrPipe()
+	if err != nil {
+		return 1, false
+	}
+
+	s.mu.Lock()
+	s.stderrTail = nil
+	s.mu.Unlock()
+
+	if err := execCmd.Start(); err != nil {
+		s.msgs <- DownloadOutputMsg{SessionID: s.ID, Line: err.Error()}
+		return 1, false
+	}
+
+	// stop is closed once Wait returns, ending the watchdog and any
+	// pending SIGKILL escalation
+	stop := make(chan struct{})
+	s.mu.Lock()
+	s.proc = execCmd
+	s.exited = stop
+	if s.cancelled || s.paused {
+		// Stopped between attempts, before the process was visible
+		killProcessGroup(execCmd, stop)
+	}
+	s.mu.Unlock()
+
+	// Watch for output while the process runs
+	var stalled atomic.Bool
+	s.touch()
+	if s.StallTimeout > 0 {
+		go s.watchdog(stop, &stalled, func() { killProcessGroup(execCmd, stop) })
+	}
+
+	// Both pipes must be drained before Wait closes them
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go s.readLines(stdout, &readers, false)
+	go s.readLines(stderr, &readers, true)
+	readers.Wait()
+
+	err = execCmd.Wait()
+	close(stop)
+
+	s.mu.Lock()
+	s.proc = nil
+	s.mu.Unlock()
+
+	exitCode := 0
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		} else {
+			exitCode = 1
+		}
+	}
+
+	return exitCode, stalled.Load()
+}
+
+// watchdog calls kill when no output arrived within StallTimeout.
+// Merging and post-processing are silent, so the window only starts
+// once they are over.
+func (s *DownloadSession) watchdog(stop <-chan struct{}, stalled *atomic.Bool, kill func()) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if s.detector.quiet() {
+				s.touch()
+				continue
+			}
+			last := time.Unix(0, s.lastActivity.Load())
+			if now.Sub(last) >= s.StallTimeout {
+				stalled.Store(true)
+				kill()
+				return
+			}
+		}
+	}
+}
+
+// touch records that the process produced output
+func (s *DownloadSession) touch() {
+	s.lastActivity.Store(time.Now().UnixNano())
+}
+
+// readLines sends every line of reader to the session stream, keeping
+// the tail of stderr for failure classification
+func (s *DownloadSession) readLines(reader io.Reader, done *sync.WaitGroup, isStderr bool) {
+	defer done.Done()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	scanner.Split(scanLinesOrCR)
+	for scanner.Scan() {
+		s.touch()
+		if line := scanner.Text(); line != "" {
+			if isStderr {
+				s.recordStderr(line)
+			}
+			if s.onLine != nil {
+				s.onLine(line)
+			}
+			s.emitLine(line)
+		}
+	}
+
+	// Keep draining so yt-dlp never blocks on a full pipe
+	if err := scanner.Err(); err != nil {
+		s.msgs <- DownloadOutputMsg{SessionID: s.ID, Line: "Failed to read yt-dlp output: " + err.Error()}
+		io.Copy(io.Discard, reader)
+	}
+}
+
+// emitLine sends the message for a line of output, followed by a stage
+// change if the line moved the download into a new stage
+func (s *DownloadSession) emitLine(line string) {
+	msg := parseOutputLine(s.ID, line)
+	if path := destinationOf(msg); path != "" {
+		s.addDestination(path)
+	}
+	s.msgs <- msg
+	if stage, changed := s.detector.observe(msg); changed {
+		s.msgs <- DownloadStageMsg{SessionID: s.ID, Stage: stage, At: time.Now()}
+	}
+}
+
+// destinationOf returns the output file a message announces, from a
+// "[download] Destination:" line or a progress record, or ""
+func destinationOf(msg tea.Msg) string {
+	switch msg := msg.(type) {
+	case DownloadProgressMsg:
+		return msg.Filename
+	case DownloadOutputMsg:
+		if path, ok := strings.CutPrefix(strings.TrimSpace(msg.Line), "[download] Destination: "); ok {
+			return strings.TrimSpace(path)
+		}
+	}
+	return ""
+}
+
+// addDestination records an output file of the session once
+func (s *DownloadSession) addDestination(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, known := range s.destinations {
+		if known == path {
+			return
+		}
+	}
+	s.destinations = append(s.destinations, path)
+}
+
+// Destinations returns the output files yt-dlp announced for this
+// session; their partial files belong to no other download
+func (s *DownloadSession) Destinations() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.destinations...)
+}
+
+// recordStderr appends line to the bounded stderr tail
+func (s *DownloadSession) recordStderr(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stderrTail = append(s.stderrTail, line)
+	if len(s.stderrTail) > stderrTailSize {
+		s.stderrTail = s.stderrTail[len(s.stderrTail)-stderrTailSize:]
+	}
+}
+
+// classifyFailure explains why the final attempt failed; cancelled and
+// paused sessions have no failure
+func (s *DownloadSession) classifyFailure(attempt Attempt) *Failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancelled || s.paused {
+		return nil
+	}
+	if attempt.Stalled {
+		return &Failure{Kind: FailureStalled, Message: fmt.Sprintf("No output for %s after %d attempts", s.StallTimeout, attempt.Number)}
+	}
+
+	failure := ClassifyFailure(s.stderrTail)
+	return &failure
+}
+
+// stallBackoff returns the delay before retry n, doubling up to a maximum
+func stallBackoff(n int) time.Duration {
+	delay := stallBackoffBase << (n - 1)
+	if delay > stallBackoffMax || delay <= 0 {
+		return stallBackoffMax
+	}
+	return delay
+}
+
+// withContinue returns cmd with --continue inserted before the URL, so a
+// restarted yt-dlp resumes from its .part files
+func withContinue(cmd Command) Command {
+	n := len(cmd.Args)
+	args := make([]string, 0, n+1)
+	args = append(args, cmd.Args[:n-1]...)
+	args = append(args, "--continue", cmd.Args[n-1])
+	return Command{Args: args}
+}
+
+// scanLinesOrCR is a bufio.SplitFunc that ends lines at \n or \r, so
+// progress updates redrawn with carriage returns arrive as separate lines
+func scanLinesOrCR(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,153 @@
+//go:build unix
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runSession runs a shell script as the session's command and returns
+// the messages it sent, cancelling it after the first retry
+func runSession(t *testing.T, script string, stallTimeout time.Duration) []any {
+	t.Helper()
+	s := NewDownloadSession(1, NewCommand("sh", "-c", script), t.TempDir())
+	s.StallTimeout = stallTimeout
+	s.Start()
+
+	var msgs []any
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case msg, ok := <-s.msgs:
+			if !ok {
+				return msgs
+			}
+			msgs = append(msgs, msg)
+			if _, retry := msg.(DownloadRetryMsg); retry {
+				s.Cancel()
+			}
+		case <-timeout:
+			s.Cancel()
+			t.Fatal("session did not finish")
+		}
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		stalled bool
+	}{
+		{
+			name:   "silent merge",
+			script: `echo '[Merger] Merging formats into "video.mp4"'; sleep 3; echo '[download] done'`,
+		},
+		{
+			name:   "silent post-processing",
+			script: `echo '[ExtractAudio] Destination: video.mp3'; sleep 3`,
+		},
+		{
+			name:    "silent download",
+			script:  `echo '[download] Destination: video.mp4'; sleep 3`,
+			stalled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			msgs := runSession(t, tt.script, time.Second)
+
+			retried := false
+			var complete *DownloadCompleteMsg
+			for _, msg := range msgs {
+				switch msg := msg.(type) {
+				case DownloadRetryMsg:
+					retried = true
+				case DownloadCompleteMsg:
+					complete = &msg
+				}
+			}
+			if retried != tt.stalled {
+				t.Errorf("retried = %v, want %v", retried, tt.stalled)
+			}
+			if complete == nil {
+				t.Fatal("no DownloadCompleteMsg")
+			}
+			if !tt.stalled && !complete.Success {
+				t.Errorf("complete = %+v, want success", *complete)
+			}
+		})
+	}
+}
+
+func TestReadLinesLongLine(t *testing.T) {
+	long := strings.Repeat("x", 1<<20)
+	s := NewDownloadSession(1, Command{}, t.TempDir())
+
+	var done sync.WaitGroup
+	done.Add(1)
+	go s.readLines(strings.NewReader(long+"\n[download] next\n"), &done, false)
+	done.Wait()
+	close(s.msgs)
+
+	var lines []string
+	for msg := range s.msgs {
+		if out, ok := msg.(DownloadOutputMsg); ok {
+			lines = append(lines, out.Line)
+		}
+	}
+	if len(lines) != 2 || lines[0] != long || lines[1] != "[download] next" {
+		t.Errorf("got %d lines, want the long line and the next one", len(lines))
+	}
+}
+
+func TestNativeStall(t *testing.T) {
+	// The playlist arrives, the segment never does
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/index.m3u8" {
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2,\nseg0.ts\n#EXT-X-ENDLIST\n")
+			return
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	s := NewDownloadSession(1, Command{}, t.TempDir())
+	s.Native = newNativeJob(DownloadOptions{URL: srv.URL + "/index.m3u8", Engine: EngineNative})
+	s.StallTimeout = time.Second
+	s.Start()
+
+	var complete *DownloadCompleteMsg
+	timeout := time.After(30 * time.Second)
+	for complete == nil {
+		select {
+		case msg := <-s.msgs:
+			if msg, ok := msg.(DownloadCompleteMsg); ok {
+				complete = &msg
+			}
+		case <-timeout:
+			s.Cancel()
+			t.Fatal("session did not finish")
+		}
+	}
+	if complete.Success || complete.Failure == nil || complete.Failure.Kind != FailureStalled {
+		t.Errorf("complete = %+v, want a stall failure", *complete)
+	}
+	if entries, _ := os.ReadDir(s.Folder); len(entries) != 0 {
+		t.Errorf("left %d file(s) behind", len(entries))
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stage is a step of a yt-dlp download
+type Stage int
+
+const (
+	StageExtract Stage = iota
+	StageVideo
+	StageAudio
+	StageMerge
+	StagePostProcess
+	StageRename
+	stageCount
+)
+
+// String returns the display name of the stage
+func (s Stage) String() string {
+	switch s {
+	case StageExtract:
+		return "Extract"
+	case StageVideo:
+		return "Video"
+	case StageAudio:
+		return "Audio"
+	case StageMerge:
+		return "Merge"
+	case StagePostProcess:
+		return "Post-process"
+	case StageRename:
+		return "Rename"
+	default:
+		return "Unknown"
+	}
+}
+
+// DownloadStageMsg reports that a session entered a new stage
+type DownloadStageMsg struct {
+	SessionID int
+	Stage     Stage
+	At        time.Time
+}
+
+// stageSpan records when a stage started and ended
+type stageSpan struct {
+	Started time.Time
+	Ended   time.Time
+}
+
+// Timeline tracks the stages of the video currently being downloaded
+type Timeline struct {
+	spans   [stageCount]stageSpan
+	current Stage
+}
+
+// NewTimeline creates a timeline that starts in the extract stage
+func NewTimeline(now time.Time) *Timeline {
+	t := &Timeline{current: StageExtract}
+	t.spans[StageExtract].Started = now
+	return t
+}
+
+// Enter ends the current stage and starts stage. Going back to the extract
+// stage starts a fresh timeline for the next playlist entry.
+func (t *Timeline) Enter(stage Stage, now time.Time) {
+	if stage == t.current {
+		return
+	}
+	if stage < t.current {
+		if stage != StageExtract {
+			return
+		}
+		t.spans = [stageCount]stageSpan{}
+	} else {
+		t.spans[t.current].Ended = now
+	}
+
+	t.current = stage
+	t.spans[stage].Started = now
+}
+
+// Finish ends the current stage
+func (t *Timeline) Finish(now time.Time) {
+	if t.spans[t.current].Ended.IsZero() {
+		t.spans[t.current].Ended = now
+	}
+}
+
+// Current returns the stage in progress
+func (t *Timeline) Current() Stage {
+	return t.current
+}
+
+// Started reports whether stage has been entered
+func (t *Timeline) Started(stage Stage) bool {
+	return !t.spans[stage].Started.IsZero()
+}
+
+// Done reports whether stage has been entered and ended
+func (t *Timeline) Done(stage Stage) bool {
+	return !t.spans[stage].Ended.IsZero()
+}
+
+// Duration returns how long stage took, or has taken so far
+func (t *Timeline) Duration(stage Stage, now time.Time) time.Duration {
+	span := t.spans[stage]
+	if span.Started.IsZero() {
+		return 0
+	}
+	if span.Ended.IsZero() {
+		return now.Sub(span.Started)
+	}
+	return span.Ended.Sub(span.Started)
+}
+
+// Output tags of yt-dlp postprocessors other than the merger
+var postprocessorTags = []string{
+	"Fixup", "ExtractAudio", "Embed", "Metadata", "VideoConvertor",
+	"VideoRemuxer", "SponsorBlock", "ModifyChapters", "MoveFiles",
+	"SplitChapters", "ThumbnailsConvertor", "SubtitlesConvertor", "Exec",
+}
+
+// Output tags of yt-dlp downloaders, printed while a stream downloads
+var downloaderTags = map[string]bool{
+	"hlsnative": true, "dashsegments": true, "ffmpeg": true, "http": true,
+	"rtmp": true, "f4m": true, "ism": true, "mhtml": true,
+	"websocket_frag": true, "niconico_dmc": true,
+}
+
+// Pattern for the "[tag] message" prefix of yt-dlp log lines
+var outputTagRe = regexp.MustCompile(`^\[([\w:-]+)\]\s`)
+
+// stageDetector derives stage transitions from a session's output stream
+type stageDetector struct {
+	mu           sync.Mutex
+	stage        Stage
+	destinations int
+	lastFile     string
+}
+
+// observe returns the stage msg moves the download into, if any
+func (d *stageDetector) observe(msg any) (Stage, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch msg := msg.(type) {
+	case DownloadOutputMsg:
+		return d.observeLine(msg.Line)
+
+	case DownloadProgressMsg:
+		if msg.Filename != "" && msg.Filename != d.lastFile {
+			d.lastFile = msg.Filename
+			return d.enterDestination(msg.Filename)
+		}
+
+	case DownloadPostprocessMsg:
+		if msg.Status != "started" {
+			return d.stage, false
+		}
+		if msg.Postprocessor == "Merger" {
+			return d.enter(StageMerge)
+		}
+		return d.enter(StagePostProcess)
+	}
+
+	return d.stage, false
+}
+
+// observeLine detects a stage from a line of human-readable output
+func (d *stageDetector) observeLine(line string) (Stage, bool) {
+	match := outputTagRe.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return d.stage, false
+	}
+	tag := match[1]
+	message := strings.TrimSpace(line[len(match[0]):])
+
+	switch {
+	case tag == "download":
+		if filename, ok := strings.CutPrefix(message, "Destination: "); ok {
+			d.lastFile = filename
+			return d.enterDestination(filename)
+		}
+		if strings.HasSuffix(message, "has already been downloaded") {
+			return d.enter(StageVideo)
+		}
+		// The next playlist entry restarts the timeline
+		if strings.HasPrefix(message, "Downloading item ") || strings.HasPrefix(message, "Downloading video ") {
+			return d.enter(StageExtract)
+		}
+		return d.stage, false
+
+	case tag == "Merger":
+		return d.enter(StageMerge)
+
+	case tag == "info" || tag == "debug" || downloaderTags[tag]:
+		return d.stage, false
+	}
+
+	for _, prefix := range postprocessorTags {
+		if strings.HasPrefix(tag, prefix) {
+			return d.enter(StagePostProcess)
+		}
+	}
+
+	// Any other tag is an extractor such as [youtube] or [generic]
+	if d.stage > StageExtract {
+		return d.enter(StageExtract)
+	}
+	return d.stage, false
+}
+
+// quiet reports whether the current stage runs ffmpeg, which prints
+// nothing until it is done however long the file takes
+func (d *stageDetector) quiet() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stage == StageMerge || d.stage == StagePostProcess
+}
+
+// enterDestination moves to the video or audio stage for a new output file
+func (d *stageDetector) enterDestination(filename string) (Stage, bool) {
+	if d.stage > StageAudio {
+		// A destination after merging belongs to the next playlist entry
+		d.destinations = 0
+	}
+	d.destinations++
+
+	if d.destinations > 1 || isAudioFile(filename) {
+		return d.enter(StageAudio)
+	}
+	return d.enter(StageVideo)
+}
+
+// enter switches the detector to stage, reporting whether it changed
+func (d *stageDetector) enter(stage Stage) (Stage, bool) {
+	if stage == d.stage {
+		return stage, false
+	}
+	if stage == StageExtract {
+		d.destinations = 0
+	}
+	d.stage = stage
+	return stage, true
+}
+
+// isAudioFile reports whether filename has an audio-only extension
+func isAudioFile(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".m4a", ".mp3", ".opus", ".aac", ".ogg", ".flac", ".wav":
+		return true
+	}
+	return false
+}
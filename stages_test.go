@@ -0,0 +1,160 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStageDetector(t *testing.T) {
+	// step is one message and the stage it should leave the detector in;
+	// changed is whether that is a transition
+	type step struct {
+		msg     any
+		stage   Stage
+		changed bool
+	}
+	line := func(s string) DownloadOutputMsg { return DownloadOutputMsg{Line: s} }
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "video and audio merged",
+			steps: []step{
+				{line("[youtube] Extracting URL: https://www.youtube.com/watch?v=abc"), StageExtract, false},
+				{line("[info] abc: Downloading 1 format(s): 137+140"), StageExtract, false},
+				{line("[download] Destination: Title [abc].f137.mp4"), StageVideo, true},
+				{line("[download]  50.0% of 10.00MiB at 1.00MiB/s ETA 00:05"), StageVideo, false},
+				{line("[download] Destination: Title [abc].f140.m4a"), StageAudio, true},
+				{line(`[Merger] Merging formats into "Title [abc].mp4"`), StageMerge, true},
+				{line("Deleting original file Title [abc].f137.mp4 (pass -k to keep)"), StageMerge, false},
+			},
+		},
+		{
+			name: "single file with post-processing",
+			steps: []step{
+				{line("[generic] Extracting URL: https://example.com/v.m3u8"), StageExtract, false},
+				{line("[hlsnative] Downloading m3u8 manifest"), StageExtract, false},
+				{line("[download] Destination: v.mp4"), StageVideo, true},
+				{line("[FixupM3u8] Fixing MPEG-TS in MP4 container of \"v.mp4\""), StagePostProcess, true},
+				{line("[Metadata] Adding metadata to \"v.mp4\""), StagePostProcess, false},
+			},
+		},
+		{
+			name: "audio only",
+			steps: []step{
+				{line("[download] Destination: song.opus"), StageAudio, true},
+				{line("[ExtractAudio] Destination: song.mp3"), StagePostProcess, true},
+			},
+		},
+		{
+			name: "progress template and postprocessor hooks",
+			steps: []step{
+				{DownloadProgressMsg{Filename: "a.f1.mp4"}, StageVideo, true},
+				{DownloadProgressMsg{Filename: "a.f1.mp4"}, StageVideo, false},
+				{DownloadProgressMsg{Filename: "a.f2.webm"}, StageAudio, true},
+				{DownloadPostprocessMsg{Postprocessor: "Merger", Status: "started"}, StageMerge, true},
+				{DownloadPostprocessMsg{Postprocessor: "Merger", Status: "finished"}, StageMerge, false},
+				{DownloadPostprocessMsg{Postprocessor: "EmbedThumbnail", Status: "started"}, StagePostProcess, true},
+			},
+		},
+		{
+			name: "already downloaded",
+			steps: []step{
+				{line("[download] Title [abc].mp4 has already been downloaded"), StageVideo, true},
+			},
+		},
+		{
+			name: "next playlist entry restarts",
+			steps: []step{
+				{line("[download] Downloading item 1 of 2"), StageExtract, false},
+				{line("[download] Destination: one.f137.mp4"), StageVideo, true},
+				{line("[download] Destination: one.f140.m4a"), StageAudio, true},
+				{line(`[Merger] Merging formats into "one.mp4"`), StageMerge, true},
+				{line("[download] Downloading item 2 of 2"), StageExtract, true},
+				{line("[download] Destination: two.f137.mp4"), StageVideo, true},
+			},
+		},
+		{
+			name: "extractor line after a download restarts",
+			steps: []step{
+				{line("[download] Destination: one.mp4"), StageVideo, true},
+				{line("[youtube] Extracting URL: https://www.youtube.com/watch?v=def"), StageExtract, true},
+				{line("[download] Destination: two.mp4"), StageVideo, true},
+			},
+		},
+		{
+			name: "destination after merging is a new entry",
+			steps: []step{
+				{line("[download] Destination: one.f137.mp4"), StageVideo, true},
+				{line(`[Merger] Merging formats into "one.mp4"`), StageMerge, true},
+				{line("[download] Destination: two.f137.mp4"), StageVideo, true},
+			},
+		},
+		{
+			name: "untagged and debug lines are ignored",
+			steps: []step{
+				{line("[download] Destination: v.mp4"), StageVideo, true},
+				{line("[debug] Invoking http downloader"), StageVideo, false},
+				{line("WARNING: something"), StageVideo, false},
+				{DownloadInfoMsg{}, StageVideo, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d stageDetector
+			for i, step := range tt.steps {
+				stage, changed := d.observe(step.msg)
+				if stage != step.stage || changed != step.changed {
+					t.Errorf("step %d: %+v gave %v (changed %v), want %v (changed %v)",
+						i, step.msg, stage, changed, step.stage, step.changed)
+				}
+			}
+		})
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+	tl := NewTimeline(start)
+	tl.Enter(StageVideo, at(2))
+	tl.Enter(StageVideo, at(3)) // no change
+	tl.Enter(StageAudio, at(10))
+	tl.Enter(StageVideo, at(11)) // going back is ignored
+	tl.Enter(StageMerge, at(15))
+
+	if tl.Current() != StageMerge {
+		t.Errorf("current = %v, want Merge", tl.Current())
+	}
+	for stage, want := range map[Stage]time.Duration{
+		StageExtract: 2 * time.Second,
+		StageVideo:   8 * time.Second,
+		StageAudio:   5 * time.Second,
+		StageMerge:   5 * time.Second, // still running at 20s
+		StageRename:  0,
+	} {
+		if got := tl.Duration(stage, at(20)); got != want {
+			t.Errorf("%v took %v, want %v", stage, got, want)
+		}
+	}
+	if !tl.Done(StageAudio) || tl.Done(StageMerge) || tl.Started(StagePostProcess) {
+		t.Error("done/started flags wrong before Finish")
+	}
+
+	tl.Finish(at(21))
+	tl.Finish(at(30)) // the first finish counts
+	if got := tl.Duration(StageMerge, at(40)); got != 6*time.Second {
+		t.Errorf("merge took %v after Finish, want 6s", got)
+	}
+
+	// Back to extract starts over for the next playlist entry
+	tl.Enter(StageExtract, at(50))
+	if tl.Started(StageVideo) || tl.Duration(StageExtract, at(51)) != time.Second {
+		t.Error("entering extract did not start a fresh timeline")
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QueueEstimate is the work left in the queue
+type QueueEstimate struct {
+	Remaining int64 // bytes left in items whose size is known
+	Unknown   int   // running or pending items of unknown size
+	ETA       time.Duration
+	HasETA    bool
+}
+
+// SessionSummary reports what the queue did since the app started
+type SessionSummary struct {
+	Started        time.Time        `json:"started"`
+	Ended          time.Time        `json:"ended"`
+	ElapsedSeconds float64          `json:"elapsed_seconds"`
+	Succeeded      int              `json:"succeeded"`
+	Failed         int              `json:"failed"`
+	Cancelled      int              `json:"cancelled"`
+	Unfinished     int              `json:"unfinished"`
+	Bytes          int64            `json:"bytes"`
+	AverageSpeed   float64          `json:"average_speed"` // bytes per second while downloading
+	Failures       []SummaryFailure `json:"failures,omitempty"`
+}
+
+// SummaryFailure is one failed item in a session summary
+type SummaryFailure struct {
+	URL    string `json:"url"`
+	Title  string `json:"title,omitempty"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Estimate sums the bytes left in running and pending items and derives
+// an ETA from the current combined throughput
+func (m Model) Estimate() QueueEstimate {
+	var e QueueEstimate
+	for _, item := range m.queue {
+		switch item.Status {
+		case QueueRunning:
+			if left, ok := m.itemRemaining(item); ok {
+				e.Remaining += left
+			} else {
+				e.Unknown++
+			}
+		case QueuePending:
+			if item.Size > 0 {
+				e.Remaining += item.Size
+			} else {
+				e.Unknown++
+			}
+		}
+	}
+
+	if speed := m.Throughput(); speed > 0 && e.Remaining > 0 {
+		e.ETA = time.Duration(float64(e.Remaining) / speed * float64(time.Second))
+		e.HasETA = true
+	}
+	return e
+}
+
+// itemRemaining returns the bytes left in a running item; metadata
+// covers every file of a merged download, progress only the current one
+func (m Model) itemRemaining(item *QueueItem) (int64, bool) {
+	s := m.sessions[item.SessionID]
+	if s == nil {
+		return 0, false
+	}
+	if info := s.Info(); info != nil && info.Size() > 0 && !item.Options.Playlist {
+		return max(info.Size()-s.Downloaded(), 0), true
+	}
+	if p, ok := s.Progress(); ok && p.Total > 0 {
+		return max(p.Total-p.Downloaded, 0), true
+	}
+	return 0, false
+}
+
+// Summary reports the items that finished since the app started
+func (m Model) Summary() SessionSummary {
+	summary := SessionSummary{Started: m.startedAt, Ended: time.Now()}
+	summary.ElapsedSeconds = summary.Ended.Sub(summary.Started).Seconds()
+
+	var active [][2]time.Time
+	for _, item := range m.queue {
+		switch item.Status {
+		case QueuePending, QueueRunning, QueuePaused:
+			summary.Unfinished++
+			continue
+		}
+
+		// Results restored from an earlier run belong to that run
+		result := item.Result
+		if result == nil || result.Finished.Before(m.startedAt) {
+			continue
+		}
+
+		summary.Bytes += result.Bytes
+		if !result.Started.IsZero() {
+			active = append(active, [2]time.Time{result.Started, result.Finished})
+		}
+
+		switch item.Status {
+		case QueueDone:
+			summary.Succeeded++
+		case QueueCancelled:
+			summary.Cancelled++
+		case QueueFailed:
+			summary.Failed++
+			failure := SummaryFailure{URL: item.Options.URL, Title: result.Title, Reason: "Unknown error"}
+			if result.Failure != nil {
+				failure.Reason = result.Failure.Kind.String()
+				failure.Detail = result.Failure.Message
+			} else if item.Err != "" {
+				failure.Detail = item.Err
+			}
+			summary.Failures = append(summary.Failures, failure)
+		}
+	}
+
+	if seconds := activeTime(active).Seconds(); seconds > 0 {
+		summary.AverageSpeed = float64(summary.Bytes) / seconds
+	}
+	return summary
+}
+
+// activeTime returns how long at least one of the intervals was running,
+// so parallel downloads are not counted twice
+func activeTime(intervals [][2]time.Time) time.Duration {
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i][0].Before(intervals[j][0])
+	})
+
+	var total time.Duration
+	var start, end time.Time
+	for _, in := range intervals {
+		if in[0].After(end) {
+			total += end.Sub(start)
+			start, end = in[0], in[1]
+			continue
+		}
+		if in[1].After(end) {
+			end = in[1]
+		}
+	}
+	return total + end.Sub(start)
+}
+
+// Finished reports whether any item finished during the session
+func (s SessionSummary) Finished() bool {
+	return s.Succeeded+s.Failed+s.Cancelled > 0
+}
+
+// String renders the summary as plain text for the terminal
+func (s SessionSummary) String() string {
+	var b strings.Builder
+	b.WriteString("Download summary\n")
+	b.WriteString(fmt.Sprintf("  Elapsed:      %s\n", formatClock(s.Ended.Sub(s.Started))))
+	b.WriteString(fmt.Sprintf("  Succeeded:    %d\n", s.Succeeded))
+	b.WriteString(fmt.Sprintf("  Failed:       %d\n", s.Failed))
+	b.WriteString(fmt.Sprintf("  Cancelled:    %d\n", s.Cancelled))
+	if s.Unfinished > 0 {
+		b.WriteString(fmt.Sprintf("  Not finished: %d\n", s.Unfinished))
+	}
+	b.WriteString(fmt.Sprintf("  Downloaded:   %s", formatBytes(s.Bytes)))
+	if s.AverageSpeed > 0 {
+		b.WriteString(fmt.Sprintf(" at %s/s on average", formatBytes(int64(s.AverageSpeed))))
+	}
+	b.WriteString("\n")
+
+	if len(s.Failures) > 0 {
+		b.WriteString("  Failures:\n")
+		for _, f := range s.Failures {
+			label := f.Title
+			if label == "" {
+				label = f.URL
+			}
+			b.WriteString(fmt.Sprintf("    ✗ %s: %s\n", label, f.Reason))
+		}
+	}
+	return b.String()
+}
+
+// WriteJSON saves the summary as indented JSON at path
+func (s SessionSummary) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// summaryPath returns a timestamped file for an on-demand summary
+func summaryPath(at time.Time) string {
+	return filepath.Join(dataDir(), "summaries", "summary-"+at.Format("20060102-150405")+".json")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActiveTime(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	span := func(from, to int) [2]time.Time {
+		return [2]time.Time{base.Add(time.Duration(from) * time.Second), base.Add(time.Duration(to) * time.Second)}
+	}
+
+	tests := []struct {
+		name      string
+		intervals [][2]time.Time
+		want      time.Duration
+	}{
+		{name: "none", want: 0},
+		{name: "one", intervals: [][2]time.Time{span(0, 10)}, want: 10 * time.Second},
+		{name: "disjoint", intervals: [][2]time.Time{span(0, 10), span(20, 25)}, want: 15 * time.Second},
+		{name: "overlapping", intervals: [][2]time.Time{span(0, 10), span(5, 15)}, want: 15 * time.Second},
+		{name: "nested", intervals: [][2]time.Time{span(0, 30), span(5, 10)}, want: 30 * time.Second},
+		{name: "touching", intervals: [][2]time.Time{span(0, 10), span(10, 20)}, want: 20 * time.Second},
+		{name: "unsorted", intervals: [][2]time.Time{span(40, 50), span(0, 10), span(5, 12)}, want: 22 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := activeTime(tt.intervals); got != tt.want {
+				t.Errorf("activeTime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// estimateModel returns a model with the given items and a session for
+// every running one
+func estimateModel(items ...*QueueItem) Model {
+	m := Model{queue: items, sessions: make(map[int]*DownloadSession), startedAt: time.Now().Add(-time.Hour)}
+	for _, item := range items {
+		if item.Status == QueueRunning {
+			m.sessions[item.SessionID] = NewDownloadSession(item.SessionID, Command{}, "")
+		}
+	}
+	return m
+}
+
+func TestEstimate(t *testing.T) {
+	const mib = 1024 * 1024
+
+	t.Run("pending sizes without throughput", func(t *testing.T) {
+		m := estimateModel(
+			&QueueItem{ID: 1, Status: QueuePending, Size: 10 * mib},
+			&QueueItem{ID: 2, Status: QueuePending},
+			&QueueItem{ID: 3, Status: QueueDone, Size: 99 * mib},
+		)
+		e := m.Estimate()
+		if e.Remaining != 10*mib || e.Unknown != 1 || e.HasETA {
+			t.Errorf("estimate = %+v, want 10MiB left, 1 unknown and no ETA", e)
+		}
+	})
+
+	t.Run("running items set the pace", func(t *testing.T) {
+		m := estimateModel(
+			&QueueItem{ID: 1, Status: QueueRunning, SessionID: 1},
+			&QueueItem{ID: 2, Status: QueueRunning, SessionID: 2},
+			&QueueItem{ID: 3, Status: QueueRunning, SessionID: 3},
+			&QueueItem{ID: 4, Status: QueuePending, Size: 20 * mib},
+		)
+		// Metadata covers the whole file
+		m.sessions[1].SetInfo(VideoInfo{FilesizeApprox: 30 * mib})
+		m.sessions[1].SetProgress(Progress{Downloaded: 10 * mib, Total: 12 * mib, Speed: 2 * mib})
+		// Progress alone
+		m.sessions[2].SetProgress(Progress{Downloaded: 6 * mib, Total: 16 * mib, Speed: 2 * mib})
+		// Nothing known yet
+		e := m.Estimate()
+		if want := int64((20 + 10 + 20) * mib); e.Remaining != want || e.Unknown != 1 {
+			t.Errorf("estimate = %+v, want %d bytes left and 1 unknown", e, want)
+		}
+		if !e.HasETA || e.ETA != 12500*time.Millisecond {
+			t.Errorf("ETA = %v (%v), want 12.5s at 4MiB/s", e.ETA, e.HasETA)
+		}
+	})
+
+	t.Run("finished progress adds no speed", func(t *testing.T) {
+		m := estimateModel(&QueueItem{ID: 1, Status: QueueRunning, SessionID: 1}, &QueueItem{ID: 2, Status: QueuePending, Size: mib})
+		m.sessions[1].SetProgress(Progress{Downloaded: mib, Total: mib, Speed: mib, Finished: true})
+		if e := m.Estimate(); e.HasETA {
+			t.Errorf("estimate = %+v, want no ETA", e)
+		}
+	})
+}
+
+func TestSummary(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	result := func(from, to time.Duration, bytes int64) *QueueResult {
+		return &QueueResult{Started: start.Add(from), Finished: start.Add(to), Bytes: bytes}
+	}
+
+	failed := result(10*time.Minute, 15*time.Minute, 0)
+	failed.Title = "Broken video"
+	failed.Failure = &Failure{Kind: FailureHTTP403, Message: "ERROR: HTTP Error 403: Forbidden"}
+
+	m := estimateModel(
+		&QueueItem{ID: 1, Status: QueueDone, Result: result(0, 10*time.Minute, 600<<20)},
+		&QueueItem{ID: 2, Status: QueueDone, Result: result(5*time.Minute, 20*time.Minute, 300<<20)},
+		&QueueItem{ID: 3, Status: QueueFailed, Options: DownloadOptions{URL: "https://example.com/broken"}, Result: failed},
+		&QueueItem{ID: 4, Status: QueueFailed, Options: DownloadOptions{URL: "https://example.com/gone"}, Err: "no such host",
+			Result: &QueueResult{Finished: start.Add(30 * time.Minute)}},
+		&QueueItem{ID: 5, Status: QueueCancelled, Result: result(40*time.Minute, 41*time.Minute, 0)},
+		&QueueItem{ID: 6, Status: QueuePending},
+		&QueueItem{ID: 7, Status: QueuePaused},
+		// Finished in an earlier run
+		&QueueItem{ID: 8, Status: QueueDone, Result: &QueueResult{Finished: start.Add(-time.Hour), Bytes: 1 << 30}},
+	)
+	m.startedAt = start
+
+	s := m.Summary()
+	if s.Succeeded != 2 || s.Failed != 2 || s.Cancelled != 1 || s.Unfinished != 2 {
+		t.Errorf("counts = %d/%d/%d/%d, want 2 succeeded, 2 failed, 1 cancelled, 2 unfinished",
+			s.Succeeded, s.Failed, s.Cancelled, s.Unfinished)
+	}
+	if s.Bytes != 900<<20 {
+		t.Errorf("bytes = %d, want 900MiB", s.Bytes)
+	}
+	// Active from 0 to 20 and 40 to 41 minutes
+	if want := float64(900<<20) / (21 * 60); s.AverageSpeed != want {
+		t.Errorf("average speed = %v, want %v", s.AverageSpeed, want)
+	}
+
+	want := []SummaryFailure{
+		{URL: "https://example.com/broken", Title: "Broken video", Reason: "HTTP 403 Forbidden", Detail: "ERROR: HTTP Error 403: Forbidden"},
+		{URL: "https://example.com/gone", Reason: "Unknown error", Detail: "no such host"},
+	}
+	if len(s.Failures) != len(want) || s.Failures[0] != want[0] || s.Failures[1] != want[1] {
+		t.Errorf("failures = %+v, want %+v", s.Failures, want)
+	}
+
+	text := s.String()
+	for _, line := range []string{"Succeeded:    2", "Not finished: 2", "Downloaded:   900.00MiB at", "✗ Broken video: HTTP 403 Forbidden", "✗ https://example.com/gone: Unknown error"} {
+		if !strings.Contains(text, line) {
+			t.Errorf("summary text lacks %q:\n%s", line, text)
+		}
+	}
+	if !s.Finished() {
+		t.Error("Finished() = false")
+	}
+	if (SessionSummary{Unfinished: 3}).Finished() {
+		t.Error("Finished() = true with nothing finished")
+	}
+}
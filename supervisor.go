@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Files inside a detached job directory
+const (
+	jobStateFile = "job.json"
+	jobLogFile   = "output.log"
+)
+
+// jobPollInterval is how often a followed job is checked for new output
+const jobPollInterval = 250 * time.Millisecond
+
+// supervisorStartTimeout is how long a new job may go without its
+// supervisor recording its PID before the job is given up as failed
+const supervisorStartTimeout = 30 * time.Second
+
+// JobState is the persisted state of a detached download
+type JobState struct {
+	ID             string    `json:"id"`
+	Command        []string  `json:"command"`
+	Folder         string    `json:"folder"`
+	StallTimeout   int       `json:"stall_timeout"`
+	SupervisorPID  int       `json:"supervisor_pid"`
+	Started        time.Time `json:"started"`
+	Finished       bool      `json:"finished"`
+	Success        bool      `json:"success"`
+	Cancelled      bool      `json:"cancelled"`
+	Paused         bool      `json:"paused"`
+	ExitCode       int       `json:"exit_code"`
+	Attempts       []Attempt `json:"attempts"`
+	Failure        *Failure  `json:"failure,omitempty"`
+	Files          []string  `json:"files"`
+	PartialRemoved int       `json:"partial_removed"`
+}
+
+// runtimeDir returns the per-user directory for detached job state
+func runtimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "hlsdownloader")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("hlsdownloader-%d", os.Getuid()))
+}
+
+// jobsDir returns the directory holding one subdirectory per detached job
+func jobsDir() string {
+	return filepath.Join(runtimeDir(), "jobs")
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over path,
+// so readers never observe a partially written file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	// Flush to disk first, or a crash could leave the renamed file empty
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readJobState loads the state file of the job in dir
+func readJobState(dir string) (JobState, error) {
+	var state JobState
+	data, err := os.ReadFile(filepath.Join(dir, jobStateFile))
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// writeJobState atomically saves the state file of the job in dir
+func writeJobState(dir string, state JobState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, jobStateFile), data)
+}
+
+// StartDetachedJob creates a job directory and launches a supervisor
+// process that runs cmd independently of the TUI
+func StartDetachedJob(cmd Command, folder string, stallTimeout time.Duration) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("Cannot locate own executable: %s", err.Error())
+	}
+
+	id := fmt.Sprintf("%d-%s", time.Now().Unix(), generateUniqueID(6))
+	dir := filepath.Join(jobsDir(), id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("Cannot create job directory: %s", err.Error())
+	}
+
+	state := JobState{
+		ID:           id,
+		Command:      cmd.Args,
+		Folder:       folder,
+		StallTimeout: int(stallTimeout / time.Second),
+		Started:      time.Now(),
+	}
+	if err := writeJobState(dir, state); err != nil {
+		return "", fmt.Errorf("Cannot write job state: %s", err.Error())
+	}
+
+	// Create the log up front so followers can open it immediately
+	logFile, err := os.Create(filepath.Join(dir, jobLogFile))
+	if err != nil {
+		return "", fmt.Errorf("Cannot create job log: %s", err.Error())
+	}
+	logFile.Close()
+
+	supervisor := exec.Command(exe, "supervise", dir)
+	detachProcess(supervisor)
+	if err := supervisor.Start(); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("Cannot start background supervisor: %s", err.Error())
+	}
+	supervisor.Process.Release()
+
+	return dir, nil
+}
+
+// RunSupervisor runs the job in dir to completion, writing output to the
+// job log and the result to the job state. It is the entry point of the
+// "supervise" subcommand and returns the process exit code.
+func RunSupervisor(dir string) int {
+	state, err := readJobState(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot read job state: %v\n", err)
+		return 1
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(dir, jobLogFile), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot open job log: %v\n", err)
+		return 1
+	}
+	defer logFile.Close()
+
+	var logMu sync.Mutex
+	writeLog := func(line string) {
+		logMu.Lock()
+		defer logMu.Unlock()
+		fmt.Fprintln(logFile, line)
+	}
+
+	// Without the PID in the job state no TUI could cancel, pause or
+	// reattach to the job, so it must not start
+	state.SupervisorPID = os.Getpid()
+	if err := writeJobState(dir, state); err != nil {
+		writeLog("Error: cannot write job state: " + err.Error())
+		fmt.Fprintf(os.Stderr, "Error: cannot write job state: %v\n", err)
+		return 1
+	}
+
+	s := NewDownloadSession(0, Command{Args: state.Command}, state.Folder)
+	s.StallTimeout = time.Duration(state.StallTimeout) * time.Second
+	s.onLine = writeLog
+
+	// SIGTERM from a TUI cancels the download
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			s.Cancel()
+		}
+	}()
+
+	// SIGUSR1 pauses it, keeping the partial files
+	pauses := make(chan os.Signal, 1)
+	notifyPause(pauses)
+	go func() {
+		for range pauses {
+			s.Pause()
+		}
+	}()
+
+	s.Started = state.Started
+	go s.streamDownloadOutput()
+
+	for msg := range s.msgs {
+		switch msg := msg.(type) {
+		case DownloadRetryMsg:
+			writeLog(formatRetryRecord(msg))
+
+		case DownloadFileMsg:
+			state.Files = append(state.Files, msg.Path)
+
+		case DownloadCompleteMsg:
+			state.Cancelled = s.Cancelled()
+			state.Success = msg.Success && !state.Cancelled
+			state.Paused = s.Paused() && !state.Success && !state.Cancelled
+			state.ExitCode = msg.ExitCode
+			state.Attempts = msg.Attempts
+			state.Failure = msg.Failure
+
+			if state.Cancelled {
+				if cleanup, ok := cleanupPartialFiles(0, s.Destinations())().(PartialCleanupMsg); ok {
+					state.PartialRemoved = cleanup.Removed
+				}
+			} else if state.Success {
+				if renamed, ok := renameDownloadedFile(0, state.Files)().(RenameCompleteMsg); ok && renamed.Note != "" {
+					writeLog(renamed.Note)
+				}
+			}
+		}
+	}
+
+	state.Finished = true
+	if err := writeJobState(dir, state); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot write job state: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// LoadDetachedSessions attaches a session to every job in the runtime
+// directory, numbering them from firstID
+func LoadDetachedSessions(firstID int) []*DownloadSession {
+	entries, err := os.ReadDir(jobsDir())
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var sessions []*DownloadSession
+	for _, name := range names {
+		dir := filepath.Join(jobsDir(), name)
+		state, err := readJobState(dir)
+		if err != nil {
+			continue
+		}
+
+		s := NewDownloadSession(firstID+len(sessions), Command{Args: state.Command}, state.Folder)
+		s.Attach(dir, state)
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+// StartDetached launches the session under a background supervisor and
+// follows its output
+func (s *DownloadSession) StartDetached() (tea.Cmd, error) {
+	dir, err := StartDetachedJob(s.Command, s.Folder, s.StallTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := readJobState(dir)
+	if err != nil {
+		return nil, err
+	}
+	return s.Attach(dir, state), nil
+}
+
+// Attach follows the log and state of the detached job in dir instead of
+// running yt-dlp in this process
+func (s *DownloadSession) Attach(dir string, state JobState) tea.Cmd {
+	s.JobDir = dir
+	s.Started = state.Started
+	s.timeline = NewTimeline(s.Started)
+	go s.followJob()
+	return s.Wait()
+}
+
+// Detached reports whether the session runs under a background supervisor
+func (s *DownloadSession) Detached() bool {
+	return s.JobDir != ""
+}
+
+// Dismiss removes the job directory of a finished detached session
+func (s *DownloadSession) Dismiss() {
+	if s.Detached() && !s.Running() {
+		os.RemoveAll(s.JobDir)
+	}
+}
+
+// followJob replays the job log as session messages, then reports the
+// result once the supervisor marks the job finished
+func (s *DownloadSession) followJob() {
+	defer close(s.msgs)
+
+	logFile, err := os.Open(filepath.Join(s.JobDir, jobLogFile))
+	if err != nil {
+		s.msgs <- DownloadCompleteMsg{SessionID: s.ID, Failure: &Failure{Message: "Job log missing: " + err.Error()}}
+		return
+	}
+	defer logFile.Close()
+
+	reader := bufio.NewReader(logFile)
+	partial := ""
+	finishing := false
+
+	for {
+		chunk, err := reader.ReadString('\n')
+		partial += chunk
+		if err == nil {
+			s.emitLine(strings.TrimRight(partial, "\r\n"))
+			partial = ""
+			continue
+		}
+
+		// Reached the end of the log; the last read after the job
+		// finished has seen every line the supervisor wrote
+		if finishing {
+			if partial != "" {
+				s.emitLine(partial)
+			}
+			break
+		}
+
+		state, err := readJobState(s.JobDir)
+		if err == nil && state.Finished {
+			finishing = true
+			continue
+		}
+		if err == nil && state.SupervisorPID == 0 && time.Since(state.Started) > supervisorStartTimeout {
+			s.msgs <- DownloadCompleteMsg{
+				SessionID: s.ID,
+				ExitCode:  1,
+				Failure:   &Failure{Message: "Background supervisor never started"},
+			}
+			return
+		}
+		if err == nil && state.SupervisorPID != 0 && !processAlive(state.SupervisorPID) {
+			// Give a supervisor that just exited a chance to be seen as finished
+			if state, err := readJobState(s.JobDir); err == nil && state.Finished {
+				finishing = true
+				continue
+			}
+			s.msgs <- DownloadCompleteMsg{
+				SessionID: s.ID,
+				ExitCode:  1,
+				Failure:   &Failure{Message: "Background supervisor exited unexpectedly"},
+			}
+			return
+		}
+
+		time.Sleep(jobPollInterval)
+	}
+
+	state, err := readJobState(s.JobDir)
+	if err != nil {
+		s.msgs <- DownloadCompleteMsg{SessionID: s.ID, ExitCode: 1, Failure: &Failure{Message: err.Error()}}
+		return
+	}
+
+	s.mu.Lock()
+	s.cancelled = state.Cancelled
+	s.paused = state.Paused
+	s.mu.Unlock()
+	s.msgs <- DownloadCompleteMsg{
+		SessionID: s.ID,
+		Success:   state.Success,
+		ExitCode:  state.ExitCode,
+		Attempts:  state.Attempts,
+		Failure:   state.Failure,
+	}
+	s.msgs <- PartialCleanupMsg{SessionID: s.ID, Removed: state.PartialRemoved}
+}
+
+// cancelDetached asks the supervisor of a detached session to cancel
+func (s *DownloadSession) cancelDetached() error {
+	state, err := readJobState(s.JobDir)
+	if err != nil {
+		return err
+	}
+	if state.Finished || state.SupervisorPID == 0 {
+		return nil
+	}
+	return terminateProcess(state.SupervisorPID)
+}
+
+// pauseDetached asks the supervisor of a detached session to pause
+func (s *DownloadSession) pauseDetached() error {
+	state, err := readJobState(s.JobDir)
+	if err != nil {
+		return err
+	}
+	if state.Finished || state.SupervisorPID == 0 {
+		return nil
+	}
+	return pauseProcess(state.SupervisorPID)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFollowJobSupervisorNeverStarted(t *testing.T) {
+	dir := t.TempDir()
+	state := JobState{ID: "test", Started: time.Now().Add(-2 * supervisorStartTimeout)}
+	if err := writeJobState(dir, state); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, jobLogFile), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewDownloadSession(1, Command{}, dir)
+	s.Attach(dir, state)
+
+	select {
+	case msg := <-s.msgs:
+		complete, ok := msg.(DownloadCompleteMsg)
+		if !ok || complete.Success || complete.Failure == nil {
+			t.Errorf("first message = %#v, want a failed DownloadCompleteMsg", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("followJob kept waiting for a supervisor that never started")
+	}
+}
@@ -1,11 +1,10 @@
 package main
 
 import (
-	"bufio"
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -14,49 +13,177 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-// Global channel for streaming download output
-var downloadOutputChan chan tea.Msg
-
-// Update handles messages and updates the model
+// Update handles messages and updates the model, saving the queue after
+// every change
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	if nm, ok := next.(Model); ok {
+		if queueMayChange(msg) {
+			nm.saveQueue()
+		}
+		return nm, cmd
+	}
+	return next, cmd
+}
+
+// queueMayChange reports whether handling msg can change the saved
+// queue; the frequent spinner ticks, output lines and progress reports
+// never do, so they skip encoding the queue
+func queueMayChange(msg tea.Msg) bool {
+	switch msg.(type) {
+	case TickMsg, DownloadOutputMsg, DownloadProgressMsg, DownloadStageMsg,
+		DownloadPostprocessMsg, DownloadRetryMsg, RenameCompleteMsg:
+		return false
+	}
+	return true
+}
+
+// update dispatches a message to its handler
+func (m Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
 	case tea.KeyMsg:
-		return m.handleKeyPress(msg)
+		limit := m.jobLimit()
+		next, cmd := m.handleKeyPress(msg)
+		// Editing the job limit takes effect immediately
+		if nm, ok := next.(Model); ok && nm.jobLimit() != limit {
+			start := nm.scheduleQueue()
+			return nm, tea.Batch(cmd, start)
+		}
+		return next, cmd
 
 	case TickMsg:
-		if m.downloading && m.downloadSuccess == nil {
+		if m.HasRunningSessions() {
 			m.spinnerFrame++
 			return m, tickSpinner()
 		}
+		m.ticking = false
 		return m, nil
 
 	case DownloadOutputMsg:
-		m.AddOutputLine(msg.Line)
-		// Continue listening for more output
-		if m.downloading && m.downloadSuccess == nil {
-			return m, waitForOutput()
+		s := m.sessions[msg.SessionID]
+		if s == nil {
+			return m, nil
 		}
-		return m, nil
+		s.AddOutputLine(msg.Line)
+		// Continue listening for more output
+		return m, s.Wait()
 
 	case DownloadCompleteMsg:
-		success := msg.Success
-		m.downloadSuccess = &success
-		// Rename downloaded file if successful
-		if success {
-			return m, renameDownloadedFile(m.outputFolder)
+		s := m.sessions[msg.SessionID]
+		if s == nil {
+			return m, nil
 		}
-		return m, nil
+		s.Finish(msg)
+		m.finishItem(s)
+		// Move on to the next queued URL
+		next := m.scheduleQueue()
+		// The supervisor of a detached download already renamed or
+		// cleaned up; drain its remaining messages
+		if s.Detached() {
+			s.Timeline().Finish(time.Now())
+			return m, tea.Batch(s.Wait(), next)
+		}
+		// Remove leftovers of a cancelled download
+		if s.Cancelled() {
+			s.Timeline().Finish(time.Now())
+			return m, tea.Batch(cleanupPartialFiles(s.ID, s.Destinations()), next)
+		}
+		// Paused downloads keep their partial files for --continue
+		if s.Paused() && !s.Succeeded() {
+			s.Timeline().Finish(time.Now())
+			return m, next
+		}
+		// Rename downloaded file if successful; the native engine writes
+		// its file under a unique name already
+		if s.Succeeded() && s.Native == nil {
+			s.Timeline().Enter(StageRename, time.Now())
+			return m, tea.Batch(renameDownloadedFile(s.ID, s.files), next)
+		}
+		s.Timeline().Finish(time.Now())
+		return m, next
 
-	case DownloadCompleteWithOutputMsg:
-		// Add all output lines
-		for _, line := range msg.Output {
-			if strings.TrimSpace(line) != "" {
-				m.AddOutputLine(line)
+	case DownloadRetryMsg:
+		s := m.sessions[msg.SessionID]
+		if s == nil {
+			return m, nil
+		}
+		s.SetRetry(msg)
+		return m, s.Wait()
+
+	case DownloadStageMsg:
+		s := m.sessions[msg.SessionID]
+		if s == nil {
+			return m, nil
+		}
+		s.Timeline().Enter(msg.Stage, msg.At)
+		return m, s.Wait()
+
+	case RenameCompleteMsg:
+		if s := m.sessions[msg.SessionID]; s != nil {
+			if msg.Note != "" {
+				s.AddOutputLine(msg.Note)
 			}
+			s.Timeline().Finish(time.Now())
+		}
+		return m, nil
+
+	case DownloadProgressMsg:
+		s := m.sessions[msg.SessionID]
+		if s == nil {
+			return m, nil
+		}
+		s.SetProgress(msg.Progress)
+		return m, s.Wait()
+
+	case DownloadPostprocessMsg:
+		s := m.sessions[msg.SessionID]
+		if s == nil {
+			return m, nil
+		}
+		if msg.Status == "started" {
+			s.AddOutputLine("[" + msg.Postprocessor + "] Post-processing")
+		}
+		return m, s.Wait()
+
+	case DownloadInfoMsg:
+		s := m.sessions[msg.SessionID]
+		if s == nil {
+			return m, nil
+		}
+		s.SetInfo(msg.Info)
+		// Remember the size of single videos for the queue estimate
+		if item := m.itemForSession(s.ID); item != nil && !item.Options.Playlist && msg.Info.Size() > 0 {
+			item.Size = msg.Info.Size()
+		}
+		return m, s.Wait()
+
+	case DownloadFileMsg:
+		s := m.sessions[msg.SessionID]
+		if s == nil {
+			return m, nil
+		}
+		s.AddFile(msg.Path)
+		s.AddOutputLine("Saved: " + msg.Path)
+		return m, s.Wait()
+
+	case ArchiveProbeMsg:
+		m.applyArchiveProbe(msg)
+		cmd := m.scheduleQueue()
+		return m, cmd
+
+	case StartQueueMsg:
+		cmd := m.scheduleQueue()
+		return m, cmd
+
+	case PlaylistResolvedMsg:
+		cmd := m.applyPlaylist(msg)
+		return m, cmd
+
+	case PartialCleanupMsg:
+		if s := m.sessions[msg.SessionID]; s != nil {
+			s.partialRemoved = msg.Removed
 		}
-		success := msg.Success
-		m.downloadSuccess = &success
 		return m, nil
 
 	default:
@@ -66,26 +193,52 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // handleKeyPress processes keyboard input
 func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.resumePrompt {
+		return m.handleResumeKey(msg)
+	}
+	if m.importing {
+		return m.handleImportKey(msg)
+	}
+	if m.picker != nil {
+		return m.handlePickerKey(msg)
+	}
+	if m.showSummary {
+		return m.handleSummaryKey(msg)
+	}
+	if m.showQueue {
+		return m.handleQueueKey(msg)
+	}
+
 	// Global quit keys
 	if msg.String() == "ctrl+c" || msg.String() == "q" {
-		if !m.downloading {
+		if !m.hasForegroundDownloads() {
 			return m, tea.Quit
 		}
+		m.err = "Downloads are still running; cancel them from the queue (Ctrl+R) first"
+		return m, nil
 	}
 
-	// If downloading and finished, any key returns to form
-	if m.downloading && m.downloadSuccess != nil {
-		m.downloading = false
-		m.downloadOutput = []string{}
-		m.downloadSuccess = nil
-		m.downloadCmd = ""
+	switch msg.String() {
+	// Ctrl+O opens the batch import dialog
+	case "ctrl+o":
+		m.importing = true
+		m.focusedField = FieldImportPath
 		m.err = ""
 		return m, nil
-	}
 
-	// Don't handle input during active download
-	if m.downloading {
+	// Ctrl+R opens the queue view
+	case "ctrl+r":
+		if len(m.queue) == 0 {
+			m.err = "The queue is empty"
+			return m, nil
+		}
+		m.showQueue = true
+		m.err = ""
 		return m, nil
+
+	// Ctrl+A adds the URLs to the queue without leaving the form
+	case "ctrl+a":
+		return m.addToQueue(false)
 	}
 
 	// Handle navigation
@@ -99,6 +252,20 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "enter":
 		return m.handleEnter()
 
+	default:
+		return m.editField(msg)
+	}
+}
+
+// editField applies cursor movement and text input to the focused field
+func (m Model) editField(msg tea.KeyMsg) (Model, tea.Cmd) {
+	// Bracketed paste delivers the whole clipboard at once
+	if msg.Paste {
+		m.InsertText(string(msg.Runes))
+		return m, nil
+	}
+
+	switch msg.String() {
 	case "left":
 		m.MoveCursorLeft()
 		return m, nil
@@ -136,45 +303,358 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	}
 }
 
-// navigateNext moves focus to next field
-func (m Model) navigateNext() Model {
-	switch m.focusedField {
-	case FieldURL:
-		m.focusedField = FieldConcurrent
-	case FieldConcurrent:
-		m.focusedField = FieldOutputFolder
-	case FieldOutputFolder:
-		m.focusedField = FieldSubtitles
-	case FieldSubtitles:
-		m.focusedField = FieldPlaylist
-	case FieldPlaylist:
-		m.focusedField = FieldExtraFlags
-	case FieldExtraFlags:
-		m.focusedField = FieldDownloadButton
-	case FieldDownloadButton:
+// handleImportKey processes keyboard input in the batch import dialog
+func (m Model) handleImportKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		m.importing = false
+		m.focusedField = FieldURL
+		m.err = ""
+		return m, nil
+
+	case "enter":
+		path := strings.TrimSpace(m.importPath)
+		if path == "" {
+			m.err = "Enter the path of a batch file"
+			return m, nil
+		}
+
+		result, err := ReadBatchFile(path, m.form, m.features)
+		if err != nil {
+			m.err = "Cannot read batch file: " + err.Error()
+			return m, nil
+		}
+
+		m.importing = false
 		m.focusedField = FieldURL
+		m.err = ""
+		first := len(m.queue)
+		m.importBatch(result)
+		if len(result.Items) > 0 {
+			m.showQueue = true
+			m.queueCursor = first
+		}
+		cmd := m.scheduleQueue()
+		return m, cmd
 	}
+
+	return m.editField(msg)
+}
+
+// handlePickerKey processes keyboard input in the playlist entry picker
+func (m Model) handlePickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.picker
+
+	// Typing a range like 1-5,8
+	if p.EditingRange {
+		switch msg.String() {
+		case "esc":
+			p.EditingRange = false
+			m.focusedField = FieldURL
+			return m, nil
+
+		case "enter":
+			if err := p.ApplyRange(p.RangeSpec); err != nil {
+				p.Err = err.Error()
+				return m, nil
+			}
+			p.EditingRange = false
+			p.Err = ""
+			m.focusedField = FieldURL
+			return m, nil
+		}
+		return m.editField(msg)
+	}
+
+	switch msg.String() {
+	case "esc", "ctrl+c", "b":
+		m.picker = nil
+		return m, nil
+	}
+
+	// Nothing else to do until the listing arrives; Enter retries a
+	// listing that failed
+	if p.Loading || len(p.Entries) == 0 {
+		if !p.Loading && msg.String() == "enter" {
+			cmd := m.openPicker(p.Options.URL)
+			return m, cmd
+		}
+		return m, nil
+	}
+
+	p.Err = ""
+	switch msg.String() {
+	case "up", "k":
+		if p.Cursor > 0 {
+			p.Cursor--
+		}
+
+	case "down", "j":
+		if p.Cursor < len(p.Entries)-1 {
+			p.Cursor++
+		}
+
+	case "pgup":
+		p.Cursor = max(p.Cursor-pickerRows, 0)
+
+	case "pgdown":
+		p.Cursor = min(p.Cursor+pickerRows, len(p.Entries)-1)
+
+	case "home", "g":
+		p.Cursor = 0
+
+	case "end", "G":
+		p.Cursor = len(p.Entries) - 1
+
+	// Space toggles the entry under the cursor and moves on
+	case " ", "space":
+		p.Selected[p.Cursor] = !p.Selected[p.Cursor]
+		if p.Cursor < len(p.Entries)-1 {
+			p.Cursor++
+		}
+
+	// Shift+arrows check entries while moving, for quick ranges
+	case "shift+down", "J":
+		p.Selected[p.Cursor] = true
+		if p.Cursor < len(p.Entries)-1 {
+			p.Cursor++
+			p.Selected[p.Cursor] = true
+		}
+
+	case "shift+up", "K":
+		p.Selected[p.Cursor] = true
+		if p.Cursor > 0 {
+			p.Cursor--
+			p.Selected[p.Cursor] = true
+		}
+
+	case "a":
+		p.ToggleAll()
+
+	case "r", ":":
+		p.EditingRange = true
+		p.RangeSpec = p.ItemSpec()
+		m.focusedField = FieldPickerRange
+		m.SetCursorPos(FieldPickerRange, len(p.RangeSpec))
+
+	// Enter queues one download limited to the checked entries
+	case "enter":
+		cmd := m.queuePickerSelection(false)
+		return m, cmd
+
+	// i queues every checked entry as its own item
+	case "i":
+		cmd := m.queuePickerSelection(true)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// handleSummaryKey processes keyboard input on the session summary
+func (m Model) handleSummaryKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "s", "b":
+		m.showSummary = false
+		return m, nil
+
+	// Save the summary as JSON
+	case "w":
+		path := summaryPath(time.Now())
+		if err := m.Summary().WriteJSON(path); err != nil {
+			m.summaryNote = "Cannot save summary: " + err.Error()
+		} else {
+			m.summaryNote = "Saved " + path
+		}
+		return m, nil
+
+	case "q", "ctrl+c":
+		if !m.hasForegroundDownloads() {
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+// handleResumeKey answers the prompt to resume the previous queue
+func (m Model) handleResumeKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "y", "enter":
+		m.resumePrompt = false
+		m.showQueue = true
+		cmd := m.scheduleQueue()
+		return m, cmd
+
+	case "n":
+		m.resumePrompt = false
+		m.discardPending()
+		m.showQueue = m.countItems(QueueRunning)+m.countItems(QueuePending) > 0
+		cmd := m.scheduleQueue()
+		return m, cmd
+
+	// Quit without deciding; the prompt comes back next launch
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// handleQueueKey processes keyboard input in the queue view
+func (m Model) handleQueueKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.err = ""
+
+	switch msg.String() {
+	case "up", "k":
+		if m.queueCursor > 0 {
+			m.queueCursor--
+		}
+		return m, nil
+
+	case "down", "j":
+		if m.queueCursor < len(m.queue)-1 {
+			m.queueCursor++
+		}
+		return m, nil
+
+	// Reorder the item under the cursor
+	case "shift+up", "K":
+		m.moveItem(-1)
+		return m, nil
+
+	case "shift+down", "J":
+		m.moveItem(1)
+		return m, nil
+
+	// Mark items for bulk actions
+	case " ", "space":
+		m.toggleMark()
+		if m.queueCursor < len(m.queue)-1 {
+			m.queueCursor++
+		}
+		return m, nil
+
+	case "a":
+		m.toggleMarkAll()
+		return m, nil
+
+	// Start the targeted items next, or change their priority
+	case "n":
+		m.togglePin()
+		return m, nil
+
+	case "]":
+		m.adjustPriority(1)
+		return m, nil
+
+	case "[":
+		m.adjustPriority(-1)
+		return m, nil
+
+	// Retry the targeted items, or every failed one
+	case "r":
+		cmd := m.retryItems(m.targetItems())
+		return m, cmd
+
+	case "R":
+		cmd := m.retryItems(m.failedItems())
+		return m, cmd
+
+	// Download archived items anyway
+	case "o":
+		cmd := m.downloadAnyway(m.targetItems())
+		return m, cmd
+
+	// Remove the targeted items from the queue
+	case "d", "delete":
+		kept, cmd := m.removeItems(m.targetItems())
+		if kept > 0 {
+			m.err = fmt.Sprintf("%d running item(s) kept; cancel or pause them before removing", kept)
+		} else {
+			m.err = ""
+		}
+		if len(m.queue) == 0 {
+			m.showQueue = false
+		}
+		return m, cmd
+
+	// Toggle between parsed output and the raw log
+	case "l":
+		m.showRawLog = !m.showRawLog
+		return m, nil
+
+	// Show the session summary
+	case "s":
+		m.showSummary = true
+		m.summaryNote = ""
+		return m, nil
+
+	// Raise or lower the number of items running at once
+	case "+", "=":
+		cmd := m.adjustJobLimit(1)
+		return m, cmd
+
+	case "-":
+		cmd := m.adjustJobLimit(-1)
+		return m, cmd
+
+	// Esc or Ctrl+C cancels the selected item
+	case "esc", "ctrl+c":
+		return m.cancelDownload()
+
+	// Pause the targeted items, or resume them
+	case "p":
+		var cmds []tea.Cmd
+		for _, item := range m.targetItems() {
+			cmds = append(cmds, m.togglePause(item))
+		}
+		clear(m.marked)
+		return m, tea.Batch(cmds...)
+
+	// End live recordings, keeping what they recorded
+	case "f":
+		if m.finishRecordings(m.targetItems()) == 0 {
+			m.err = "No live recording selected"
+		}
+		clear(m.marked)
+		return m, nil
+
+	// Return to the form; the queue keeps running
+	case "b":
+		m.showQueue = false
+		return m, nil
+
+	// Drop finished items from the queue
+	case "x":
+		m.clearFinished()
+		m.importReport = nil
+		if len(m.queue) == 0 {
+			m.showQueue = false
+		}
+		return m, nil
+
+	// Quitting is safe once only background downloads are left
+	case "q":
+		if !m.hasForegroundDownloads() {
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+
+	return m, nil
+}
+
+// navigateNext moves focus to next field
+func (m Model) navigateNext() Model {
+	fields := m.formFields()
+	i := m.fieldIndex(m.focusedField)
+	m.focusedField = fields[(i+1)%len(fields)]
 	return m
 }
 
 // navigatePrevious moves focus to previous field
 func (m Model) navigatePrevious() Model {
-	switch m.focusedField {
-	case FieldURL:
-		m.focusedField = FieldDownloadButton
-	case FieldConcurrent:
-		m.focusedField = FieldURL
-	case FieldOutputFolder:
-		m.focusedField = FieldConcurrent
-	case FieldSubtitles:
-		m.focusedField = FieldOutputFolder
-	case FieldPlaylist:
-		m.focusedField = FieldSubtitles
-	case FieldExtraFlags:
-		m.focusedField = FieldPlaylist
-	case FieldDownloadButton:
-		m.focusedField = FieldExtraFlags
-	}
+	fields := m.formFields()
+	i := m.fieldIndex(m.focusedField)
+	m.focusedField = fields[(i-1+len(fields))%len(fields)]
 	return m
 }
 
@@ -182,11 +662,35 @@ func (m Model) navigatePrevious() Model {
 func (m Model) handleEnter() (Model, tea.Cmd) {
 	switch m.focusedField {
 	case FieldSubtitles:
-		m.subtitles = !m.subtitles
+		m.form.Subtitles = !m.form.Subtitles
 		return m, nil
 
 	case FieldPlaylist:
-		m.playlist = !m.playlist
+		m.form.Playlist = !m.form.Playlist
+		return m, nil
+
+	case FieldPlaylistReverse:
+		m.form.PlaylistReverse = !m.form.PlaylistReverse
+		return m, nil
+
+	case FieldDetach:
+		m.form.Detach = !m.form.Detach
+		return m, nil
+
+	case FieldLowPriority:
+		m.form.LowPriority = !m.form.LowPriority
+		return m, nil
+
+	case FieldArchive:
+		m.form.Archive = nextArchiveMode(m.form.Archive)
+		return m, nil
+
+	case FieldEngine:
+		m.form.Engine = nextEngine(m.form.Engine)
+		return m, nil
+
+	case FieldLive:
+		m.form.Live = nextLiveMode(m.form.Live)
 		return m, nil
 
 	case FieldDownloadButton:
@@ -202,11 +706,35 @@ func (m Model) handleEnter() (Model, tea.Cmd) {
 func (m Model) handleSpace() (Model, tea.Cmd) {
 	switch m.focusedField {
 	case FieldSubtitles:
-		m.subtitles = !m.subtitles
+		m.form.Subtitles = !m.form.Subtitles
 		return m, nil
 
 	case FieldPlaylist:
-		m.playlist = !m.playlist
+		m.form.Playlist = !m.form.Playlist
+		return m, nil
+
+	case FieldPlaylistReverse:
+		m.form.PlaylistReverse = !m.form.PlaylistReverse
+		return m, nil
+
+	case FieldDetach:
+		m.form.Detach = !m.form.Detach
+		return m, nil
+
+	case FieldLowPriority:
+		m.form.LowPriority = !m.form.LowPriority
+		return m, nil
+
+	case FieldArchive:
+		m.form.Archive = nextArchiveMode(m.form.Archive)
+		return m, nil
+
+	case FieldEngine:
+		m.form.Engine = nextEngine(m.form.Engine)
+		return m, nil
+
+	case FieldLive:
+		m.form.Live = nextLiveMode(m.form.Live)
 		return m, nil
 
 	default:
@@ -226,8 +754,8 @@ func (m Model) handleCharInput(msg tea.KeyMsg) (Model, tea.Cmd) {
 
 	ch := rune(key[0])
 
-	// For concurrent field, only allow digits
-	if m.focusedField == FieldConcurrent {
+	// For numeric fields, only allow digits
+	if m.isNumericField(m.focusedField) {
 		if !unicode.IsDigit(ch) {
 			return m, nil
 		}
@@ -241,206 +769,126 @@ func (m Model) handleCharInput(msg tea.KeyMsg) (Model, tea.Cmd) {
 	return m, nil
 }
 
-// startDownload validates inputs and starts download
+// startDownload queues the URLs in the form and opens the queue view
 func (m Model) startDownload() (Model, tea.Cmd) {
-	// Clear previous error
-	m.err = ""
-
-	// Validate inputs
-	if err := ValidateInputs(m); err != nil {
-		m.err = err.Error()
+	// An empty URL field just shows what is already queued
+	if strings.TrimSpace(m.form.URL) == "" && len(m.queue) > 0 {
+		m.err = ""
+		m.showQueue = true
 		return m, nil
 	}
 
-	// Start download with live output streaming
-	return m, startDownloadWithOutput(&m)
-}
-
-// startDownloadWithOutput initiates download and captures output
-func startDownloadWithOutput(m *Model) tea.Cmd {
-	cmd := BuildCommand(*m)
-	m.downloadCmd = cmd
-	m.downloading = true
-	m.downloadOutput = []string{}
-	m.downloadSuccess = nil
-	m.spinnerFrame = 0
-
-	return tea.Batch(
-		streamDownloadOutput(cmd),
-		tickSpinner(),
-	)
-}
-
-// streamDownloadOutput runs yt-dlp and streams output line by line
-func streamDownloadOutput(cmdStr string) tea.Cmd {
-	return func() tea.Msg {
-		cmdParts := parseCommand(cmdStr)
-		if len(cmdParts) == 0 {
-			return DownloadCompleteMsg{Success: false, ExitCode: 1}
+	// A single playlist URL opens the entry picker first
+	if urls := splitURLs(m.form.URL); m.form.Playlist && len(urls) == 1 {
+		m.err = ""
+		if err := ValidateParallelJobs(m.parallelJobs); err != nil {
+			m.err = err.Error()
+			return m, nil
 		}
+		m.importReport = nil
+		cmd := m.openPicker(urls[0])
+		return m, cmd
+	}
+	return m.addToQueue(true)
+}
 
-		// Create channel for streaming
-		downloadOutputChan = make(chan tea.Msg, 100)
-
-		go func() {
-			defer close(downloadOutputChan)
-
-			execCmd := exec.Command(cmdParts[0], cmdParts[1:]...)
-
-			// Use CombinedOutput for simpler streaming
-			outputPipe, err := execCmd.StdoutPipe()
-			if err != nil {
-				downloadOutputChan <- DownloadCompleteMsg{Success: false, ExitCode: 1}
-				return
-			}
-
-			stderrPipe, err := execCmd.StderrPipe()
-			if err != nil {
-				downloadOutputChan <- DownloadCompleteMsg{Success: false, ExitCode: 1}
-				return
-			}
-
-			if err := execCmd.Start(); err != nil {
-				downloadOutputChan <- DownloadCompleteMsg{Success: false, ExitCode: 1}
-				return
-			}
-
-			// Read stdout with byte-by-byte for carriage returns
-			go func() {
-				buf := make([]byte, 1)
-				line := strings.Builder{}
-				for {
-					n, err := outputPipe.Read(buf)
-					if n > 0 {
-						ch := buf[0]
-						if ch == '\n' {
-							// New line - send accumulated line
-							if line.Len() > 0 {
-								downloadOutputChan <- DownloadOutputMsg{Line: line.String()}
-								line.Reset()
-							}
-						} else if ch == '\r' {
-							// Carriage return - send current line and reset
-							if line.Len() > 0 {
-								downloadOutputChan <- DownloadOutputMsg{Line: line.String()}
-								line.Reset()
-							}
-						} else {
-							line.WriteByte(ch)
-						}
-					}
-					if err != nil {
-						if line.Len() > 0 {
-							downloadOutputChan <- DownloadOutputMsg{Line: line.String()}
-						}
-						break
-					}
-				}
-			}()
+// addToQueue queues the URLs in the form, starts the queue and
+// optionally switches to the queue view
+func (m Model) addToQueue(show bool) (Model, tea.Cmd) {
+	// Clear previous error
+	m.err = ""
 
-			// Read stderr
-			go func() {
-				scanner := bufio.NewScanner(stderrPipe)
-				for scanner.Scan() {
-					downloadOutputChan <- DownloadOutputMsg{Line: scanner.Text()}
-				}
-			}()
-
-			// Wait for command
-			err = execCmd.Wait()
-
-			success := err == nil
-			exitCode := 0
-			if err != nil {
-				if exitErr, ok := err.(*exec.ExitError); ok {
-					exitCode = exitErr.ExitCode()
-				} else {
-					exitCode = 1
-				}
-			}
+	if err := ValidateParallelJobs(m.parallelJobs); err != nil {
+		m.err = err.Error()
+		return m, nil
+	}
 
-			downloadOutputChan <- DownloadCompleteMsg{Success: success, ExitCode: exitCode}
-		}()
+	first := len(m.queue)
+	m.importReport = nil
+	if _, err := m.enqueueForm(); err != nil {
+		m.err = err.Error()
+		return m, nil
+	}
 
-		// Wait for and return first message
-		return waitForOutput()()
+	if show {
+		m.showQueue = true
+		m.queueCursor = first
 	}
+	cmd := m.scheduleQueue()
+	return m, cmd
 }
 
-// waitForOutput waits for the next output message from the download channel
-func waitForOutput() tea.Cmd {
-	return func() tea.Msg {
-		if downloadOutputChan != nil {
-			return <-downloadOutputChan
-		}
+// startTicking starts the spinner animation unless it is already running
+func (m *Model) startTicking() tea.Cmd {
+	if m.ticking {
 		return nil
 	}
+	m.ticking = true
+	return tickSpinner()
 }
 
-// renameDownloadedFile renames the most recently downloaded file to a unique name
-func renameDownloadedFile(outputFolder string) tea.Cmd {
-	return func() tea.Msg {
-		// Get the actual folder path
-		folder := outputFolder
-		if folder == "" || folder == "." {
-			folder, _ = os.Getwd()
-		}
+// cancelDownload cancels the marked queue items, or the one under the
+// cursor, terminating the yt-dlp process group of running ones
+func (m Model) cancelDownload() (Model, tea.Cmd) {
+	cmd := m.cancelItems(m.targetItems())
+	return m, cmd
+}
 
-		// Find the most recently created video file
-		files, err := os.ReadDir(folder)
-		if err != nil {
-			return nil
+// renameDownloadedFile renames the files yt-dlp reported to unique names.
+// Without a report nothing is renamed: with parallel jobs the newest
+// video in the folder may belong to another download.
+func renameDownloadedFile(sessionID int, reported []string) tea.Cmd {
+	return func() tea.Msg {
+		if len(reported) == 0 {
+			return RenameCompleteMsg{SessionID: sessionID, Note: "yt-dlp reported no downloaded file, so nothing was renamed"}
 		}
-
-		var newestFile os.DirEntry
-		var newestTime time.Time
-
-		videoExtensions := map[string]bool{
-			".mp4": true, ".mkv": true, ".webm": true, ".avi": true,
-			".mov": true, ".flv": true, ".wmv": true, ".m4v": true,
+		for _, path := range reported {
+			renameToUniqueID(path)
 		}
+		return RenameCompleteMsg{SessionID: sessionID}
+	}
+}
 
-		for _, file := range files {
-			if file.IsDir() {
-				continue
-			}
-
-			ext := strings.ToLower(filepath.Ext(file.Name()))
-			if !videoExtensions[ext] {
-				continue
-			}
-
-			info, err := file.Info()
-			if err != nil {
-				continue
-			}
+// renameToUniqueID renames path to a unique 20-character name, keeping its extension
+func renameToUniqueID(path string) error {
+	newName := generateUniqueID(20) + filepath.Ext(path)
+	return os.Rename(path, filepath.Join(filepath.Dir(path), newName))
+}
 
-			if newestFile == nil || info.ModTime().After(newestTime) {
-				newestFile = file
-				newestTime = info.ModTime()
+// cleanupPartialFiles removes the .part, .ytdl and fragment leftovers
+// of the session's own destination files; other downloads sharing the
+// output folder keep theirs
+func cleanupPartialFiles(sessionID int, destinations []string) tea.Cmd {
+	return func() tea.Msg {
+		removed := 0
+		for _, dest := range destinations {
+			for _, path := range partialFilesOf(dest) {
+				if err := os.Remove(path); err == nil {
+					removed++
+				}
 			}
 		}
+		return PartialCleanupMsg{SessionID: sessionID, Removed: removed}
+	}
+}
 
-		if newestFile == nil {
-			return nil
-		}
-
-		// Generate unique 20-character alphanumeric name
-		uniqueName := generateUniqueID(20)
-		ext := filepath.Ext(newestFile.Name())
-		newName := uniqueName + ext
-
-		oldPath := filepath.Join(folder, newestFile.Name())
-		newPath := filepath.Join(folder, newName)
+// partialFilesOf lists the unfinished artifacts yt-dlp may have written
+// for the destination file dest
+func partialFilesOf(dest string) []string {
+	paths := []string{dest + ".part", dest + ".ytdl"}
 
-		// Rename the file
-		err = os.Rename(oldPath, newPath)
-		if err != nil {
-			return nil
+	// Fragments are named like video.mp4.part-Frag12
+	dir, prefix := filepath.Dir(dest), filepath.Base(dest)+".part-Frag"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return paths
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
 		}
-
-		return nil
 	}
+	return paths
 }
 
 // generateUniqueID generates a unique alphanumeric ID of specified length
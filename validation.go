@@ -4,18 +4,63 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// ValidateInputs validates all form inputs before download
-func ValidateInputs(m Model) error {
+// maxParallelJobs caps how many queue items may run at once
+const maxParallelJobs = 16
+
+// Rate limits accepted by yt-dlp's -r option
+var rateLimitRe = regexp.MustCompile(`^(?i)\d+(\.\d+)?[KMGTP]?$`)
+
+// Dates accepted by yt-dlp's --dateafter and --datebefore
+var (
+	absoluteDateRe = regexp.MustCompile(`^\d{8}$`)
+	relativeDateRe = regexp.MustCompile(`^(now|today|yesterday)([+-]\d+(day|week|month|year)s?)?$`)
+)
+
+// Durations as seconds, m:s or h:m:s
+var durationRe = regexp.MustCompile(`^\d+(:\d{1,2}){0,2}$`)
+
+// ValidateInputs validates a snapshot of form inputs before download
+func ValidateInputs(o DownloadOptions) error {
 	// Validate URL is not empty
-	if strings.TrimSpace(m.url) == "" {
+	if strings.TrimSpace(o.URL) == "" {
 		return fmt.Errorf("URL cannot be empty")
 	}
 
+	// Validate stall timeout
+	if timeout := strings.TrimSpace(o.StallTimeout); timeout != "" {
+		if n, err := strconv.Atoi(timeout); err != nil || n < 0 {
+			return fmt.Errorf("Stall timeout must be a whole number of seconds")
+		}
+	}
+
+	// Validate rate limit, e.g. 500K or 4.2M
+	if rate := strings.TrimSpace(o.RateLimit); rate != "" && !rateLimitRe.MatchString(rate) {
+		return fmt.Errorf("Rate limit must be a number with optional K/M/G suffix, e.g. 2M")
+	}
+
+	// Validate retry counts
+	if err := validateRetries("Retries", o.Retries); err != nil {
+		return err
+	}
+	if err := validateRetries("Fragment retries", o.FragmentRetries); err != nil {
+		return err
+	}
+
+	// Validate socket timeout
+	if timeout := strings.TrimSpace(o.SocketTimeout); timeout != "" {
+		if n, err := strconv.ParseFloat(timeout, 64); err != nil || n <= 0 {
+			return fmt.Errorf("Socket timeout must be a positive number of seconds")
+		}
+	}
+
 	// Validate output folder
-	folder := strings.TrimSpace(m.outputFolder)
+	folder := strings.TrimSpace(o.OutputFolder)
 	if folder == "" {
 		return fmt.Errorf("Output folder cannot be empty")
 	}
@@ -46,5 +91,146 @@ func ValidateInputs(m Model) error {
 		os.Remove(testFile)
 	}
 
+	// Validate archive mode
+	switch o.Archive {
+	case ArchiveOff, ArchiveFolder, ArchiveGlobal:
+	default:
+		return fmt.Errorf("Download archive must be off, folder or global")
+	}
+
+	// Validate playlist range and filters
+	if err := validatePlaylistFilters(o); err != nil {
+		return err
+	}
+
+	// Validate options against the download engine
+	if err := validateEngine(o); err != nil {
+		return err
+	}
+
+	// Validate picked playlist entries
+	if o.PlaylistItems != "" && !playlistItemsRe.MatchString(o.PlaylistItems) {
+		return fmt.Errorf("Playlist items must look like 1-5,8,10-")
+	}
+
+	// Validate extra flags parse as shell words
+	if _, err := ParseArgs(o.ExtraFlags); err != nil {
+		return fmt.Errorf("Invalid extra flags: %s", err.Error())
+	}
+
+	return nil
+}
+
+// validatePlaylistFilters checks the Playlist Mode range and filter
+// fields; they are ignored, and not checked, for single videos
+func validatePlaylistFilters(o DownloadOptions) error {
+	if !o.Playlist {
+		return nil
+	}
+
+	// Range: 1-based indexes with the end not before the start
+	start, err := optionalCount("Start index", o.PlaylistStart)
+	if err != nil {
+		return err
+	}
+	end, err := optionalCount("End index", o.PlaylistEnd)
+	if err != nil {
+		return err
+	}
+	if start > 0 && end > 0 && end < start {
+		return fmt.Errorf("End index must not be before start index")
+	}
+	if _, err := optionalCount("Max downloads", o.MaxDownloads); err != nil {
+		return err
+	}
+
+	// Upload dates, absolute or relative to today
+	after, err := normalizeDate(o.DateAfter)
+	if err != nil {
+		return fmt.Errorf("Uploaded after: %s", err.Error())
+	}
+	before, err := normalizeDate(o.DateBefore)
+	if err != nil {
+		return fmt.Errorf("Uploaded before: %s", err.Error())
+	}
+	if absoluteDateRe.MatchString(after) && absoluteDateRe.MatchString(before) && after > before {
+		return fmt.Errorf("Uploaded after must not be later than uploaded before")
+	}
+
+	// Title filters; yt-dlp uses Python regexes, which mostly share
+	// Go's syntax
+	if _, err := regexp.Compile(strings.TrimSpace(o.TitleMatch)); err != nil {
+		return fmt.Errorf("Invalid title match regex: %s", err.Error())
+	}
+	if _, err := regexp.Compile(strings.TrimSpace(o.TitleReject)); err != nil {
+		return fmt.Errorf("Invalid title exclude regex: %s", err.Error())
+	}
+
+	// Durations as seconds or [h:]m:s
+	for _, d := range []struct{ name, value string }{
+		{"Min duration", o.MinDuration},
+		{"Max duration", o.MaxDuration},
+	} {
+		if value := strings.TrimSpace(d.value); value != "" && !durationRe.MatchString(value) {
+			return fmt.Errorf("%s must be seconds or [h:]m:s, e.g. 90 or 1:30", d.name)
+		}
+	}
+	minDuration, maxDuration := strings.TrimSpace(o.MinDuration), strings.TrimSpace(o.MaxDuration)
+	if minDuration != "" && maxDuration != "" && parseClock(minDuration) > parseClock(maxDuration) {
+		return fmt.Errorf("Min duration must not be longer than max duration")
+	}
+
+	return nil
+}
+
+// optionalCount parses an optional positive whole number; empty is 0
+func optionalCount(name, value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive whole number", name)
+	}
+	return n, nil
+}
+
+// normalizeDate converts YYYY-MM-DD to yt-dlp's YYYYMMDD and checks
+// relative dates such as today-2weeks; empty stays empty
+func normalizeDate(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" || relativeDateRe.MatchString(value) {
+		return value, nil
+	}
+
+	compact := strings.ReplaceAll(value, "-", "")
+	if !absoluteDateRe.MatchString(compact) {
+		return "", fmt.Errorf("date must be YYYY-MM-DD, YYYYMMDD or like today-2weeks")
+	}
+	if _, err := time.Parse("20060102", compact); err != nil {
+		return "", fmt.Errorf("%s is not a valid date", value)
+	}
+	return compact, nil
+}
+
+// ValidateParallelJobs checks the global queue job limit
+func ValidateParallelJobs(value string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 1 || n > maxParallelJobs {
+		return fmt.Errorf("Parallel downloads must be between 1 and %d", maxParallelJobs)
+	}
+	return nil
+}
+
+// validateRetries checks a retry count is a non-negative integer or "infinite"
+func validateRetries(label, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "infinite" {
+		return nil
+	}
+	if n, err := strconv.Atoi(value); err != nil || n < 0 {
+		return fmt.Errorf("%s must be a whole number or \"infinite\"", label)
+	}
 	return nil
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeDate(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "", want: ""},
+		{input: "  ", want: ""},
+		{input: "2024-01-31", want: "20240131"},
+		{input: "20240131", want: "20240131"},
+		{input: " 2024-1-31", wantErr: true},
+		{input: "2024-02-30", wantErr: true},
+		{input: "2024-13-01", wantErr: true},
+		{input: "2024-02-29", want: "20240229"},
+		{input: "2023-02-29", wantErr: true},
+		{input: "today", want: "today"},
+		{input: "Yesterday", want: "yesterday"},
+		{input: "today-2weeks", want: "today-2weeks"},
+		{input: "now+1day", want: "now+1day"},
+		{input: "today-3months", want: "today-3months"},
+		{input: "today-2fortnights", wantErr: true},
+		{input: "last week", wantErr: true},
+		{input: "31/01/2024", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeDate(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizeDate(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeDate(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePlaylistFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		opts DownloadOptions
+		err  string // substring of the error; empty for none
+	}{
+		{name: "single videos are not checked", opts: DownloadOptions{PlaylistStart: "x", DateAfter: "soon"}},
+		{name: "all valid", opts: DownloadOptions{Playlist: true, PlaylistStart: "2", PlaylistEnd: "5", MaxDownloads: "3",
+			DateAfter: "2024-01-01", DateBefore: "today", TitleMatch: "^Part", MinDuration: "1:00", MaxDuration: "1:00:00"}},
+		{name: "start not a number", opts: DownloadOptions{Playlist: true, PlaylistStart: "first"}, err: "Start index"},
+		{name: "zero end", opts: DownloadOptions{Playlist: true, PlaylistEnd: "0"}, err: "End index"},
+		{name: "end before start", opts: DownloadOptions{Playlist: true, PlaylistStart: "5", PlaylistEnd: "2"}, err: "not be before"},
+		{name: "bad max downloads", opts: DownloadOptions{Playlist: true, MaxDownloads: "-1"}, err: "Max downloads"},
+		{name: "bad date", opts: DownloadOptions{Playlist: true, DateBefore: "2024-02-30"}, err: "Uploaded before"},
+		{name: "dates reversed", opts: DownloadOptions{Playlist: true, DateAfter: "2024-06-01", DateBefore: "20240101"}, err: "not be later"},
+		{name: "relative dates are not compared", opts: DownloadOptions{Playlist: true, DateAfter: "today", DateBefore: "20000101"}},
+		{name: "bad title regex", opts: DownloadOptions{Playlist: true, TitleReject: "(unclosed"}, err: "title exclude"},
+		{name: "bad duration", opts: DownloadOptions{Playlist: true, MinDuration: "1m30s"}, err: "Min duration must be"},
+		{name: "durations reversed", opts: DownloadOptions{Playlist: true, MinDuration: "10:00", MaxDuration: "90"}, err: "not be longer"},
+		{name: "equal durations", opts: DownloadOptions{Playlist: true, MinDuration: "1:30", MaxDuration: "90"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePlaylistFilters(tt.opts)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("error = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
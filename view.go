@@ -3,11 +3,24 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 // View renders the UI
 func (m Model) View() string {
-	if m.downloading {
+	if m.resumePrompt {
+		return m.renderResumePrompt()
+	}
+	if m.importing {
+		return m.renderImportView()
+	}
+	if m.picker != nil {
+		return m.renderPickerView()
+	}
+	if m.showSummary {
+		return m.renderSummaryView()
+	}
+	if m.showQueue {
 		return m.renderDownloadView()
 	}
 	return m.renderFormView()
@@ -25,40 +38,100 @@ func (m Model) renderFormView() string {
 	b.WriteString("\n")
 
 	// URL field
-	b.WriteString(m.renderTextField(FieldURL, "URL", m.url, true))
+	b.WriteString(m.renderTextField(FieldURL, "URL", m.form.URL, true))
 	b.WriteString("\n")
 
 	// Concurrent fragments field
-	b.WriteString(m.renderTextField(FieldConcurrent, "Concurrent Fragments (-N)", m.concurrent, false))
+	b.WriteString(m.renderTextField(FieldConcurrent, "Concurrent Fragments (-N)", m.form.Concurrent, false))
+	b.WriteString("\n")
+
+	// Queue items running at once
+	b.WriteString(m.renderCompactField(FieldParallelJobs, "Parallel Downloads", m.parallelJobs))
+	b.WriteString("\n")
+
+	// Stall timeout field
+	b.WriteString(m.renderTextField(FieldStallTimeout, "Stall Timeout (seconds, 0 = off)", m.form.StallTimeout, false))
 	b.WriteString("\n")
 
 	// Output folder field
-	b.WriteString(m.renderTextField(FieldOutputFolder, "Output Folder", m.outputFolder, false))
+	b.WriteString(m.renderTextField(FieldOutputFolder, "Output Folder", m.form.OutputFolder, false))
 	b.WriteString("\n")
 
 	// Subtitles checkbox
-	b.WriteString(m.renderCheckbox(FieldSubtitles, "Subtitles", m.subtitles))
+	b.WriteString(m.renderCheckbox(FieldSubtitles, "Subtitles", m.form.Subtitles))
 	b.WriteString("\n")
 
 	// Playlist checkbox
-	b.WriteString(m.renderCheckbox(FieldPlaylist, "Playlist Mode", m.playlist))
+	b.WriteString(m.renderCheckbox(FieldPlaylist, "Playlist Mode", m.form.Playlist))
+	b.WriteString("\n")
+
+	// Playlist range and filters
+	if m.form.Playlist {
+		b.WriteString(m.renderPlaylistFields())
+	}
+
+	// Detach checkbox
+	b.WriteString(m.renderCheckbox(FieldDetach, "Run in Background (keeps going after quit)", m.form.Detach))
+	b.WriteString("\n")
+
+	// Network and resource controls
+	b.WriteString(m.renderCompactField(FieldRateLimit, "Rate Limit (-r, e.g. 2M)", m.form.RateLimit))
+	b.WriteString("\n")
+	b.WriteString(m.renderCompactField(FieldRetries, "Retries", m.form.Retries))
+	b.WriteString("\n")
+	b.WriteString(m.renderCompactField(FieldFragmentRetries, "Fragment Retries", m.form.FragmentRetries))
+	b.WriteString("\n")
+	b.WriteString(m.renderCompactField(FieldSocketTimeout, "Socket Timeout (s)", m.form.SocketTimeout))
+	b.WriteString("\n")
+	b.WriteString(m.renderCheckbox(FieldLowPriority, "Low CPU/IO Priority (nice/ionice)", m.form.LowPriority))
+	b.WriteString("\n")
+	b.WriteString(m.renderChoice(FieldArchive, "Download Archive", archiveModeLabel(m.form.Archive)))
+	b.WriteString("\n")
+	b.WriteString(m.renderChoice(FieldEngine, "Download Engine", engineLabel(m.form.Engine)))
 	b.WriteString("\n")
 
+	// Live recording, native engine only
+	if m.form.Engine == EngineNative {
+		b.WriteString(m.renderChoice(FieldLive, "Live Recording", liveModeLabel(m.form.Live)))
+		b.WriteString("\n")
+		if m.form.Live != LiveOff {
+			b.WriteString(m.renderCompactField(FieldRecordFor, "  Record For ([h:]m:s)", m.form.RecordFor))
+			b.WriteString("\n")
+		}
+	}
+
 	// Extra flags field
-	b.WriteString(m.renderTextField(FieldExtraFlags, "Extra Flags", m.extraFlags, false))
+	b.WriteString(m.renderTextField(FieldExtraFlags, "Extra Flags", m.form.ExtraFlags, false))
 	b.WriteString("\n")
 
 	// Command preview
 	b.WriteString("  ──────────────────────────────────────────────────────────────────────────────\n")
 	b.WriteString("  Command Preview:\n")
-	cmd := BuildCommand(m)
-	b.WriteString(m.wrapText(cmd, 76, "  "))
+	// Several URLs become separate queue items; preview the first one
+	opts := m.form
+	urls := splitURLs(opts.URL)
+	if len(urls) > 0 {
+		opts.URL = urls[0]
+	}
+	if cmd, err := BuildCommand(opts, m.features); err != nil {
+		b.WriteString(m.wrapText(err.Error(), 76, "  "))
+	} else if opts.Engine == EngineNative {
+		b.WriteString(m.wrapText(newNativeJob(opts).String(), 76, "  "))
+	} else {
+		b.WriteString(m.wrapText(cmd.String(), 76, "  "))
+	}
 	b.WriteString("\n")
+	if len(urls) > 1 {
+		b.WriteString(fmt.Sprintf("  (+%d more URLs, each queued as its own download)\n", len(urls)-1))
+	} else if m.form.Playlist && len(urls) == 1 {
+		b.WriteString("  (Download lists the playlist first so you can pick entries; Ctrl+A queues it all)\n")
+	}
 	b.WriteString("  ──────────────────────────────────────────────────────────────────────────────\n")
 	b.WriteString("\n")
 
 	// Download button
 	b.WriteString(m.renderButton(FieldDownloadButton, "[ Download ]"))
+	b.WriteString("    Ctrl+A: Add to queue")
 	b.WriteString("\n\n")
 
 	// Error message
@@ -67,44 +140,540 @@ func (m Model) renderFormView() string {
 		b.WriteString("\n")
 	}
 
+	// Queue summary
+	b.WriteString(m.renderQueueSummary())
+	b.WriteString(m.renderImportReport())
+
 	// Help text
-	b.WriteString("  Tab/↑↓: Navigate  |  Space: Toggle  |  Enter: Submit  |  q/Ctrl+C: Quit\n")
+	b.WriteString("  Tab/↑↓: Navigate  |  Space: Toggle  |  Enter: Download  |  q/Ctrl+C: Quit\n")
+	b.WriteString("  Ctrl+O: Import batch file  |  Ctrl+R: Queue\n")
 
 	return b.String()
 }
 
-// renderDownloadView renders the download progress view
+// renderDownloadView renders the queue with the details of the selected item
 func (m Model) renderDownloadView() string {
 	var b strings.Builder
 
 	b.WriteString("\n")
 	b.WriteString("  ╔════════════════════════════════════════════════════════════════════════════╗\n")
-	b.WriteString("  ║                              Download in Progress                          ║\n")
+	b.WriteString("  ║                               Download Queue                               ║\n")
 	b.WriteString("  ╚════════════════════════════════════════════════════════════════════════════╝\n")
 	b.WriteString("\n")
 
-	// Command that was executed
-	b.WriteString("  Executing:\n")
-	b.WriteString(m.wrapText(m.downloadCmd, 76, "  "))
-	b.WriteString("\n")
+	b.WriteString(m.renderQueue())
 	b.WriteString("  ──────────────────────────────────────────────────────────────────────────────\n")
 	b.WriteString("\n")
 
+	item := m.SelectedItem()
+	if item == nil {
+		b.WriteString("  (queue is empty)\n")
+		return b.String()
+	}
+
+	b.WriteString(m.renderArchiveNote(item))
+
+	if s := m.sessions[item.SessionID]; s != nil {
+		b.WriteString(m.renderSessionDetails(s))
+	} else if item.Result != nil {
+		b.WriteString(m.renderItemResult(item))
+	} else if item.Err != "" {
+		b.WriteString(m.renderError(item.Err))
+		b.WriteString("\n\n")
+	} else if item.Status == QueuePaused {
+		b.WriteString(fmt.Sprintf("  ⏸ PAUSED: %s\n  Press p to continue from the partial files\n\n", item.Options.URL))
+	} else if item.Status == QueueArchived {
+		b.WriteString(m.wrapText(item.Options.URL, 76, "  "))
+		b.WriteString("\n\n")
+	} else {
+		waiting := "Waiting to start (" + item.Priority.String() + " priority"
+		if item.Pinned {
+			waiting += ", pinned next"
+		}
+		b.WriteString(fmt.Sprintf("  %s): %s\n\n", waiting, item.Options.URL))
+	}
+
+	if m.err != "" {
+		b.WriteString(m.renderError(m.err))
+		b.WriteString("\n\n")
+	}
+
+	b.WriteString(m.renderImportReport())
+	b.WriteString("  ↑↓: Select  |  Space: Mark  |  a: Mark all  |  K/J: Move  |  n: Pin next\n")
+	b.WriteString("  [/]: Priority  |  p: Pause/resume (yt-dlp only)  |  r: Retry\n")
+	b.WriteString("  R: Retry all failed  |  d: Remove  |  Esc: Cancel  |  o: Download anyway\n")
+	b.WriteString("  +/-: Parallel jobs  |  l: Raw log  |  s: Summary  |  x: Clear finished\n")
+	b.WriteString("  b: Back to form")
+	if !m.hasForegroundDownloads() {
+		b.WriteString("  |  q: Quit")
+	}
+	b.WriteString("\n")
+	if s := m.sessions[item.SessionID]; s != nil && s.Recording() && s.Running() {
+		b.WriteString("  f: Finish recording (keeps what was recorded)\n")
+	}
+
+	return b.String()
+}
+
+// renderSummaryView renders the session summary
+func (m Model) renderSummaryView() string {
+	var b strings.Builder
+
+	b.WriteString("\n")
+	b.WriteString("  ╔════════════════════════════════════════════════════════════════════════════╗\n")
+	b.WriteString("  ║                               Session Summary                              ║\n")
+	b.WriteString("  ╚════════════════════════════════════════════════════════════════════════════╝\n")
+	b.WriteString("\n")
+
+	for _, line := range strings.Split(strings.TrimRight(m.Summary().String(), "\n"), "\n") {
+		b.WriteString("  " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	if m.summaryNote != "" {
+		b.WriteString(m.wrapText(m.summaryNote, 76, "  "))
+		b.WriteString("\n\n")
+	}
+
+	b.WriteString("  w: Save as JSON  |  Esc: Back to queue\n")
+	return b.String()
+}
+
+// renderResumePrompt asks whether to resume the queue of the previous run
+func (m Model) renderResumePrompt() string {
+	var b strings.Builder
+
+	b.WriteString("\n")
+	b.WriteString("  ╔════════════════════════════════════════════════════════════════════════════╗\n")
+	b.WriteString("  ║                            Resume Previous Queue?                          ║\n")
+	b.WriteString("  ╚════════════════════════════════════════════════════════════════════════════╝\n")
+	b.WriteString("\n")
+
+	waiting, interrupted := 0, 0
+	for _, item := range m.queue {
+		if item.Status != QueuePending || item.ID >= m.firstNewID {
+			continue
+		}
+		waiting++
+		if item.Continue {
+			interrupted++
+		}
+	}
+
+	b.WriteString(fmt.Sprintf("  %d item(s) were waiting when the app last exited", waiting))
+	if interrupted > 0 {
+		b.WriteString(fmt.Sprintf(";\n  %d interrupted item(s) will continue from their partial files", interrupted))
+	}
+	b.WriteString(".\n\n")
+	b.WriteString(m.renderQueue())
+	b.WriteString("\n")
+	b.WriteString("  y/Enter: Resume  |  n: Discard waiting items  |  q: Quit (ask again next time)\n")
+
+	return b.String()
+}
+
+// renderImportView renders the batch import dialog
+func (m Model) renderImportView() string {
+	var b strings.Builder
+
+	b.WriteString("\n")
+	b.WriteString("  ╔════════════════════════════════════════════════════════════════════════════╗\n")
+	b.WriteString("  ║                              Import Batch File                             ║\n")
+	b.WriteString("  ╚════════════════════════════════════════════════════════════════════════════╝\n")
+	b.WriteString("\n")
+
+	b.WriteString(m.renderTextField(FieldImportPath, "Batch File", m.importPath, true))
+	b.WriteString("\n\n")
+
+	b.WriteString("  One URL per line; blank lines and lines starting with # ; or ] are skipped.\n")
+	b.WriteString("  Overrides after a URL are merged over the current form values:\n")
+	b.WriteString("    https://example.com/v/1 subs=1 playlist=0 folder='/media/My Videos'\n")
+	for _, line := range batchKeyLines(74) {
+		b.WriteString("  " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	if m.err != "" {
+		b.WriteString(m.renderError(m.err))
+		b.WriteString("\n\n")
+	}
+
+	b.WriteString("  Enter: Import  |  Esc: Back to form\n")
+	return b.String()
+}
+
+// renderPickerView renders the playlist entry checklist
+func (m Model) renderPickerView() string {
+	p := m.picker
+	var b strings.Builder
+
+	b.WriteString("\n")
+	b.WriteString("  ╔════════════════════════════════════════════════════════════════════════════╗\n")
+	b.WriteString("  ║                              Pick Playlist Entries                         ║\n")
+	b.WriteString("  ╚════════════════════════════════════════════════════════════════════════════╝\n")
+	b.WriteString("\n")
+
+	if p.Loading {
+		b.WriteString(m.wrapText("Listing "+p.Options.URL+" ...", 76, "  "))
+		b.WriteString("\n\n")
+		b.WriteString("  Esc: Back to form\n")
+		return b.String()
+	}
+
+	if len(p.Entries) == 0 {
+		b.WriteString(m.renderError("Cannot list playlist: " + p.Err))
+		b.WriteString("\n\n")
+		b.WriteString("  Enter: Retry  |  Esc: Back to form\n")
+		return b.String()
+	}
+
+	title := p.Title
+	if title == "" {
+		title = p.Options.URL
+	}
+	b.WriteString(m.wrapText("Playlist: "+title, 76, "  "))
+	b.WriteString("\n")
+	b.WriteString(fmt.Sprintf("  %d of %d entries selected\n\n", p.SelectedCount(), len(p.Entries)))
+
+	start := max(0, min(p.Cursor-pickerRows/2, len(p.Entries)-pickerRows))
+	end := min(len(p.Entries), start+pickerRows)
+	if start > 0 {
+		b.WriteString(fmt.Sprintf("      ↑ %d more\n", start))
+	}
+	for i := start; i < end; i++ {
+		b.WriteString(m.renderPickerEntry(i, i == p.Cursor))
+		b.WriteString("\n")
+	}
+	if end < len(p.Entries) {
+		b.WriteString(fmt.Sprintf("      ↓ %d more\n", len(p.Entries)-end))
+	}
+	b.WriteString("\n")
+
+	if p.EditingRange {
+		b.WriteString(m.renderTextField(FieldPickerRange, "Select Range (e.g. 1-5,8,10-)", p.RangeSpec, false))
+		b.WriteString("\n\n")
+	}
+
+	if p.Err != "" {
+		b.WriteString(m.renderError(p.Err))
+		b.WriteString("\n\n")
+	}
+
+	if p.EditingRange {
+		b.WriteString("  Enter: Apply range  |  Esc: Cancel\n")
+		return b.String()
+	}
+	b.WriteString("  ↑↓/j/k: Move  |  Space: Toggle  |  Shift+↑↓: Select while moving  |  a: All/none\n")
+	b.WriteString("  r: Type a range  |  Enter: Queue as one download  |  i: Queue each entry\n")
+	b.WriteString("  Esc: Back to form\n")
+	return b.String()
+}
+
+// renderPickerEntry renders one line of the playlist checklist
+func (m Model) renderPickerEntry(i int, selected bool) string {
+	p := m.picker
+	entry := p.Entries[i]
+
+	cursor := " "
+	if selected {
+		cursor = ">"
+	}
+	check := "[ ]"
+	if p.Selected[i] {
+		check = "[x]"
+	}
+
+	duration := "--:--"
+	if entry.Duration > 0 {
+		duration = formatClock(time.Duration(entry.Duration * float64(time.Second)))
+	}
+
+	title := entry.Title
+	if title == "" {
+		title = entry.ID
+	}
+	if len(title) > 52 {
+		title = title[:49] + "..."
+	}
+
+	return fmt.Sprintf(" %s %s %4d. %8s  %s", cursor, check, entry.Index, duration, title)
+}
+
+// renderImportReport renders the outcome of the last batch import,
+// listing the first rejected lines
+func (m Model) renderImportReport() string {
+	const maxLines = 6
+
+	if len(m.importReport) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(m.wrapText(m.importReport[0], 76, "  "))
+	b.WriteString("\n")
+	for i, line := range m.importReport[1:] {
+		if i == maxLines {
+			b.WriteString(fmt.Sprintf("    ... and %d more\n", len(m.importReport)-1-maxLines))
+			break
+		}
+		b.WriteString(m.wrapText(line, 72, "    "))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// renderQueue renders one line per queue item with its status and progress,
+// scrolled to keep the selected item visible
+func (m Model) renderQueue() string {
+	const rows = 8
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("  Queue: %d running, %d pending, %d paused, %d done, %d failed, %d cancelled\n",
+		m.countItems(QueueRunning), m.countItems(QueuePending), m.countItems(QueuePaused),
+		m.countItems(QueueDone), m.countItems(QueueFailed), m.countItems(QueueCancelled)))
+	if n := m.countItems(QueueArchived); n > 0 {
+		b.WriteString(fmt.Sprintf("  %d item(s) already in the download archive (o: download anyway)\n", n))
+	}
+	b.WriteString(fmt.Sprintf("  Jobs: %d/%d  |  Throughput: %s/s\n",
+		m.countItems(QueueRunning), m.jobLimit(), formatBytes(int64(m.Throughput()))))
+	b.WriteString(m.renderEstimate())
+
+	start := max(0, min(m.queueCursor-rows/2, len(m.queue)-rows))
+	end := min(len(m.queue), start+rows)
+	if start > 0 {
+		b.WriteString(fmt.Sprintf("      ↑ %d more\n", start))
+	}
+	for i := start; i < end; i++ {
+		b.WriteString(m.renderQueueItem(m.queue[i], i == m.queueCursor))
+		b.WriteString("\n")
+	}
+	if end < len(m.queue) {
+		b.WriteString(fmt.Sprintf("      ↓ %d more\n", len(m.queue)-end))
+	}
+	return b.String()
+}
+
+// renderEstimate renders the bytes and time left in the queue
+func (m Model) renderEstimate() string {
+	e := m.Estimate()
+	if e.Remaining == 0 && e.Unknown == 0 {
+		return ""
+	}
+
+	line := "  Remaining: " + formatBytes(e.Remaining)
+	if e.Unknown > 0 {
+		line += fmt.Sprintf(" (+%d of unknown size)", e.Unknown)
+	}
+	if e.HasETA {
+		line += "  |  ETA " + formatClock(e.ETA)
+		if e.Unknown > 0 {
+			line += "+"
+		}
+	}
+	return line + "\n"
+}
+
+// renderQueueItem renders a single queue line
+func (m Model) renderQueueItem(item *QueueItem, selected bool) string {
+	cursor := " "
+	if selected {
+		cursor = ">"
+	}
+
+	icon := "·"
+	switch item.Status {
+	case QueueRunning:
+		icon = spinnerFrames[m.spinnerFrame%len(spinnerFrames)]
+	case QueueDone:
+		icon = "✓"
+	case QueueFailed:
+		icon = "✗"
+	case QueueCancelled:
+		icon = "⊘"
+	case QueuePaused:
+		icon = "⏸"
+	case QueueArchived:
+		icon = "≡"
+	}
+
+	status := item.Status.String()
+	if s := m.sessions[item.SessionID]; s != nil && item.Status == QueuePaused {
+		if p, ok := s.Progress(); ok && p.Total > 0 {
+			status = fmt.Sprintf("paused %.1f%%", p.Percent)
+		}
+	}
+	if s := m.sessions[item.SessionID]; s != nil && item.Status == QueueRunning {
+		if p, ok := s.Progress(); ok && p.Live {
+			status = "REC " + formatClock(p.Recorded)
+		} else if ok && p.Total > 0 {
+			status = fmt.Sprintf("%5.1f%%", p.Percent)
+			if p.Speed > 0 {
+				status += " " + formatBytes(int64(p.Speed)) + "/s"
+			}
+		}
+	}
+
+	// Marked for bulk actions
+	mark := " "
+	if m.marked[item.ID] {
+		mark = "*"
+	}
+
+	// Scheduling hints for waiting items
+	badge := " "
+	switch {
+	case item.Pinned:
+		badge = "!"
+	case item.Priority == PriorityHigh:
+		badge = "↑"
+	case item.Priority == PriorityLow:
+		badge = "↓"
+	}
+
+	label := m.itemLabel(item)
+	if item.Options.PlaylistItems != "" {
+		label = "[" + item.Options.PlaylistItems + "] " + label
+	}
+	if item.Options.Detach {
+		label = "[bg] " + label
+	}
+	if len(label) > 48 {
+		label = label[:45] + "..."
+	}
+
+	return fmt.Sprintf(" %s%s %s %s %-20s %s", cursor, mark, icon, badge, status, label)
+}
+
+// renderQueueSummary renders a one-line queue overview below the form
+func (m Model) renderQueueSummary() string {
+	if len(m.queue) == 0 {
+		return ""
+	}
+
+	waiting := m.countItems(QueuePending) + m.countItems(QueuePaused)
+	return fmt.Sprintf("  Queue: %d running at %s/s, %d waiting, %d finished (Ctrl+R to view)\n\n",
+		m.countItems(QueueRunning), formatBytes(int64(m.Throughput())), waiting,
+		len(m.queue)-m.countItems(QueueRunning)-waiting)
+}
+
+// renderArchiveNote explains how many videos of an item are already in
+// the download archive
+func (m Model) renderArchiveNote(item *QueueItem) string {
+	switch {
+	case item.Options.Archive == ArchiveOff:
+		return ""
+	case item.Status == QueueArchived:
+		note := fmt.Sprintf("≡ ALREADY DOWNLOADED: all %d video(s) are in %s", item.ArchiveTotal, archivePath(item.Options))
+		return m.wrapText(note, 76, "  ") + "\n    Press o to download anyway\n\n"
+	case item.Force && item.ArchiveHits > 0:
+		return "  Downloading anyway, ignoring the download archive\n\n"
+	case item.ArchiveHits > 0:
+		return fmt.Sprintf("  %d of %d video(s) already in the archive will be skipped\n\n",
+			item.ArchiveHits, item.ArchiveTotal)
+	case item.Status == QueuePending && item.needsProbe():
+		return "  Checking the download archive...\n\n"
+	}
+	return ""
+}
+
+// renderItemResult renders the saved outcome of an item finished in a
+// previous run
+func (m Model) renderItemResult(item *QueueItem) string {
+	var b strings.Builder
+	r := item.Result
+
+	b.WriteString(m.wrapText(item.Options.URL, 76, "  "))
+	b.WriteString("\n\n")
+
+	switch item.Status {
+	case QueueDone:
+		b.WriteString("  ✓ SUCCESS\n")
+	case QueueCancelled:
+		b.WriteString("  ⊘ CANCELLED\n")
+	default:
+		if r.Failure != nil {
+			b.WriteString(fmt.Sprintf("  ✗ FAILED: %s\n", r.Failure.Kind))
+			if r.Failure.Message != "" {
+				b.WriteString(m.wrapText(r.Failure.Message, 72, "    "))
+				b.WriteString("\n")
+			}
+			b.WriteString(m.wrapText("Hint: "+r.Failure.Hint(), 72, "    "))
+			b.WriteString("\n")
+		} else {
+			b.WriteString(fmt.Sprintf("  ✗ FAILED (exit code %d)\n", r.ExitCode))
+		}
+	}
+
+	b.WriteString(fmt.Sprintf("  Finished %s", r.Finished.Format("2006-01-02 15:04")))
+	if r.Attempts > 1 {
+		b.WriteString(fmt.Sprintf(" after %d attempts", r.Attempts))
+	}
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+// renderSessionDetails renders the status, stages and output of a session
+func (m Model) renderSessionDetails(s *DownloadSession) string {
+	var b strings.Builder
+
+	// Title of the video once yt-dlp reports it
+	if info := s.Info(); info != nil && info.Title != "" {
+		title := "Title: " + info.Title
+		if info.Duration > 0 {
+			title += " (" + formatClock(time.Duration(info.Duration*float64(time.Second))) + ")"
+		}
+		b.WriteString(m.wrapText(title, 76, "  "))
+		b.WriteString("\n\n")
+	}
+
+	// Command that was executed
+	b.WriteString("  Executing:\n")
+	b.WriteString(m.wrapText(s.Describe(), 76, "  "))
+	b.WriteString("\n\n")
+
 	// Status with spinner
-	if m.downloadSuccess == nil {
+	if s.Running() {
 		spinner := spinnerFrames[m.spinnerFrame%len(spinnerFrames)]
-		b.WriteString(fmt.Sprintf("  %s Download in progress...\n", spinner))
-	} else if *m.downloadSuccess {
+		if s.Cancelled() {
+			b.WriteString(fmt.Sprintf("  %s Cancelling...\n", spinner))
+		} else if s.Paused() {
+			b.WriteString(fmt.Sprintf("  %s Pausing...\n", spinner))
+		} else if s.Finishing() {
+			b.WriteString(fmt.Sprintf("  %s Finishing recording...\n", spinner))
+		} else if p, ok := s.Progress(); ok {
+			b.WriteString(fmt.Sprintf("  %s %s\n", spinner, m.renderProgress(p)))
+		} else {
+			b.WriteString(fmt.Sprintf("  %s Download in progress...\n", spinner))
+		}
+	} else if s.Cancelled() {
+		b.WriteString("  ⊘ CANCELLED\n")
+		if s.partialRemoved > 0 {
+			b.WriteString(fmt.Sprintf("  Removed %d partial file(s)\n", s.partialRemoved))
+		}
+	} else if s.Succeeded() {
 		b.WriteString("  ✓ SUCCESS\n")
+	} else if s.Paused() {
+		b.WriteString("  ⏸ PAUSED  (p continues from the partial files)\n")
 	} else {
-		b.WriteString("  ✗ FAILED\n")
+		b.WriteString(m.renderFailure(s))
 	}
+	b.WriteString(m.renderAttempts(s))
+	b.WriteString("\n")
+
+	// Stage timeline
+	b.WriteString(m.renderTimeline(s))
 	b.WriteString("\n")
 
-	// Output (last 15 lines)
-	b.WriteString("  Output:\n")
+	// Output (last 10 lines), progress reports only in the raw log
+	outputLines := s.LastLogLines(10)
+	if m.showRawLog {
+		b.WriteString("  Raw Output:\n")
+		outputLines = s.LastOutputLines(10)
+	} else {
+		b.WriteString("  Output:\n")
+	}
 	b.WriteString("  ──────────────────────────────────────────────────────────────────────────────\n")
-	outputLines := m.GetLastOutputLines(15)
 	if len(outputLines) == 0 {
 		b.WriteString("  (waiting for output...)\n")
 	} else {
@@ -117,13 +686,158 @@ func (m Model) renderDownloadView() string {
 	b.WriteString("  ──────────────────────────────────────────────────────────────────────────────\n")
 	b.WriteString("\n")
 
-	if m.downloadSuccess != nil {
-		b.WriteString("  Press any key to return to form...\n")
+	return b.String()
+}
+
+// renderFailure renders the FAILED status with the classified reason and a hint
+func (m Model) renderFailure(s *DownloadSession) string {
+	failure := s.Failure()
+	if failure == nil {
+		return "  ✗ FAILED\n"
 	}
 
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("  ✗ FAILED: %s\n", failure.Kind))
+	if failure.Message != "" {
+		b.WriteString(m.wrapText(failure.Message, 72, "    "))
+		b.WriteString("\n")
+	}
+	b.WriteString(m.wrapText("Hint: "+failure.Hint(), 72, "    "))
+	b.WriteString("\n")
 	return b.String()
 }
 
+// renderAttempts renders retry state while running and the attempt history
+// once a download that needed retries has finished
+func (m Model) renderAttempts(s *DownloadSession) string {
+	if s.Running() {
+		if retry := s.Retry(); retry != nil {
+			return fmt.Sprintf("  ↻ Attempt %d of %d (previous attempt stalled: %s)\n",
+				retry.Attempt, maxStallRetries+1, retry.Reason)
+		}
+		return ""
+	}
+
+	attempts := s.Attempts()
+	if len(attempts) < 2 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("  Attempts:\n")
+	for _, a := range attempts {
+		outcome := fmt.Sprintf("exited with code %d", a.ExitCode)
+		if a.Stalled {
+			outcome = "stalled"
+		}
+		b.WriteString(fmt.Sprintf("    #%d %s after %s\n", a.Number, outcome, formatClock(a.Ended.Sub(a.Started))))
+	}
+	return b.String()
+}
+
+// renderTimeline renders the stage checklist with the duration of each stage
+func (m Model) renderTimeline(s *DownloadSession) string {
+	t := s.Timeline()
+	if t == nil {
+		return ""
+	}
+
+	now := time.Now()
+	spinner := spinnerFrames[m.spinnerFrame%len(spinnerFrames)]
+
+	var b strings.Builder
+	b.WriteString("  Stages:\n")
+	for stage := StageExtract; stage < stageCount; stage++ {
+		mark := "·"
+		switch {
+		case t.Done(stage) && stage == t.Current() && !s.Running() && s.Paused() && !s.Succeeded():
+			mark = "⏸"
+		case t.Done(stage) && stage == t.Current() && !s.Running() && !s.Succeeded():
+			mark = "✗"
+		case t.Done(stage):
+			mark = "✓"
+		case t.Started(stage):
+			mark = spinner
+		case stage < t.Current():
+			mark = "–"
+		}
+
+		duration := ""
+		if t.Started(stage) {
+			duration = formatClock(t.Duration(stage, now))
+		}
+		b.WriteString(fmt.Sprintf("    %s %-14s %s\n", mark, stage, duration))
+	}
+	return b.String()
+}
+
+// renderProgress renders a progress bar and, on a second line, size,
+// speed, ETA and fragment counters
+func (m Model) renderProgress(p Progress) string {
+	const barWidth = 40
+
+	if p.Live {
+		return m.renderRecording(p, barWidth)
+	}
+
+	var bar, details []string
+
+	if p.Total > 0 {
+		filled := int(p.Percent / 100 * barWidth)
+		filled = max(0, min(filled, barWidth))
+		bar = append(bar, "["+strings.Repeat("█", filled)+strings.Repeat("░", barWidth-filled)+"]")
+		bar = append(bar, fmt.Sprintf("%5.1f%%", p.Percent))
+
+		estimate := ""
+		if p.TotalEstimated {
+			estimate = "~"
+		}
+		details = append(details, fmt.Sprintf("%s / %s%s", formatBytes(p.Downloaded), estimate, formatBytes(p.Total)))
+	} else {
+		bar = append(bar, "Downloading...")
+		details = append(details, formatBytes(p.Downloaded))
+	}
+
+	if p.Speed > 0 {
+		details = append(details, fmt.Sprintf("at %s/s", formatBytes(int64(p.Speed))))
+	}
+	if p.HasETA {
+		details = append(details, "ETA "+formatClock(p.ETA))
+	}
+	if p.FragmentCount > 0 {
+		details = append(details, fmt.Sprintf("frag %d/%d", p.Fragment, p.FragmentCount))
+	}
+
+	return strings.Join(bar, " ") + "\n    " + strings.Join(details, "  ")
+}
+
+// renderRecording renders a live recording: the recorded duration, as
+// a bar when there is a limit, and how far behind the live edge it is
+func (m Model) renderRecording(p Progress, barWidth int) string {
+	var bar, details []string
+
+	if p.RecordLimit > 0 {
+		filled := int(p.Percent / 100 * float64(barWidth))
+		filled = max(0, min(filled, barWidth))
+		bar = append(bar, "["+strings.Repeat("█", filled)+strings.Repeat("░", barWidth-filled)+"]")
+		bar = append(bar, fmt.Sprintf("● REC %s / %s", formatClock(p.Recorded), formatClock(p.RecordLimit)))
+	} else {
+		bar = append(bar, "● REC "+formatClock(p.Recorded))
+	}
+
+	details = append(details, formatBytes(p.Downloaded))
+	if p.Speed > 0 {
+		details = append(details, fmt.Sprintf("at %s/s", formatBytes(int64(p.Speed))))
+	}
+	details = append(details, formatClock(p.Behind)+" behind live edge")
+	details = append(details, fmt.Sprintf("%d segments", p.Fragment))
+	if p.Missed > 0 {
+		details = append(details, fmt.Sprintf("%d missed", p.Missed))
+	}
+
+	return strings.Join(bar, " ") + "\n    " + strings.Join(details, "  ")
+}
+
 // renderTextField renders a text input field
 func (m Model) renderTextField(field Field, label, value string, required bool) string {
 	focused := m.focusedField == field
@@ -179,6 +893,56 @@ func (m Model) renderTextField(field Field, label, value string, required bool)
 	return b.String()
 }
 
+// renderCompactField renders a single-line text input for short values
+func (m Model) renderCompactField(field Field, label, value string) string {
+	const width = 20
+
+	focused := m.focusedField == field
+	cursor := m.GetCursorPos(field)
+
+	focusIndicator := " "
+	if focused {
+		focusIndicator = ">"
+	}
+
+	displayValue := value
+	if focused && cursor <= len(value) {
+		displayValue = value[:cursor] + "_" + value[cursor:]
+	}
+	if len(displayValue) > width {
+		displayValue = displayValue[len(displayValue)-width:]
+	}
+
+	return fmt.Sprintf(" %s   %-26s [%-*s]", focusIndicator, label+":", width, displayValue)
+}
+
+// renderPlaylistFields renders the range and filter fields shown in
+// Playlist Mode
+func (m Model) renderPlaylistFields() string {
+	var b strings.Builder
+	b.WriteString(m.renderCompactField(FieldPlaylistStart, "  Start Index", m.form.PlaylistStart))
+	b.WriteString("\n")
+	b.WriteString(m.renderCompactField(FieldPlaylistEnd, "  End Index", m.form.PlaylistEnd))
+	b.WriteString("\n")
+	b.WriteString(m.renderCheckbox(FieldPlaylistReverse, "Reverse Order", m.form.PlaylistReverse))
+	b.WriteString("\n")
+	b.WriteString(m.renderCompactField(FieldMaxDownloads, "  Max Downloads", m.form.MaxDownloads))
+	b.WriteString("\n")
+	b.WriteString(m.renderCompactField(FieldDateAfter, "  Uploaded After", m.form.DateAfter))
+	b.WriteString("\n")
+	b.WriteString(m.renderCompactField(FieldDateBefore, "  Uploaded Before", m.form.DateBefore))
+	b.WriteString("\n")
+	b.WriteString(m.renderCompactField(FieldTitleMatch, "  Title Matches (regex)", m.form.TitleMatch))
+	b.WriteString("\n")
+	b.WriteString(m.renderCompactField(FieldTitleReject, "  Title Excludes (regex)", m.form.TitleReject))
+	b.WriteString("\n")
+	b.WriteString(m.renderCompactField(FieldMinDuration, "  Min Duration ([h:]m:s)", m.form.MinDuration))
+	b.WriteString("\n")
+	b.WriteString(m.renderCompactField(FieldMaxDuration, "  Max Duration ([h:]m:s)", m.form.MaxDuration))
+	b.WriteString("\n")
+	return b.String()
+}
+
 // renderCheckbox renders a checkbox field
 func (m Model) renderCheckbox(field Field, label string, checked bool) string {
 	focused := m.focusedField == field
@@ -196,6 +960,16 @@ func (m Model) renderCheckbox(field Field, label string, checked bool) string {
 	return fmt.Sprintf(" %s   [%s] %s", focusIndicator, checkMark, label)
 }
 
+// renderChoice renders a field that cycles through fixed values
+func (m Model) renderChoice(field Field, label, value string) string {
+	focusIndicator := " "
+	if m.focusedField == field {
+		focusIndicator = ">"
+	}
+
+	return fmt.Sprintf(" %s   %-26s < %s >", focusIndicator, label+":", value)
+}
+
 // renderButton renders a button
 func (m Model) renderButton(field Field, label string) string {
 	focused := m.focusedField == field